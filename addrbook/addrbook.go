@@ -0,0 +1,196 @@
+// Package addrbook maps destination addresses to operator-chosen labels
+// and categories, per chain. It is kept on the cold machine, so a label
+// named in an intent is resolved from local data rather than trusted from
+// the online side.
+package addrbook
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"coldsign/intent"
+)
+
+// Version is the address book file format version.
+const Version = 1
+
+// Entry is one labelled address.
+type Entry struct {
+	Label    string
+	Address  common.Address
+	Category string          // e.g. "exchange", "vendor"; may be empty
+	Chains   map[uint64]bool // nil: every chain
+	Deny     bool            // denylisted: never a valid destination
+}
+
+// String describes the entry for the review, e.g. "acme (vendor)".
+func (e *Entry) String() string {
+	var notes []string
+	if e.Category != "" {
+		notes = append(notes, e.Category)
+	}
+	if e.Deny {
+		notes = append(notes, "DENYLISTED")
+	}
+	if len(notes) == 0 {
+		return e.Label
+	}
+	return e.Label + " (" + strings.Join(notes, ", ") + ")"
+}
+
+func (e *Entry) onChain(chainID uint64) bool {
+	return e.Chains == nil || e.Chains[chainID]
+}
+
+// Book is a loaded address book.
+type Book struct {
+	Entries []*Entry
+	Source  string // file path
+}
+
+// file is the on-disk form:
+//
+//	{
+//	  "v": 1,
+//	  "entries": [
+//	    {"label": "treasury", "address": "0x...", "category": "internal"},
+//	    {"label": "acme-payroll", "address": "0x...", "category": "vendor", "chains": [1, 8453]},
+//	    {"label": "drainer", "address": "0x...", "deny": true}
+//	  ]
+//	}
+type file struct {
+	V       int         `json:"v"`
+	Entries []fileEntry `json:"entries"`
+}
+
+type fileEntry struct {
+	Label    string   `json:"label"`
+	Address  string   `json:"address"`
+	Category string   `json:"category,omitempty"`
+	Chains   []uint64 `json:"chains,omitempty"` // omitted: every chain
+	Deny     bool     `json:"deny,omitempty"`
+}
+
+// Load reads an address book file.
+func Load(path string) (*Book, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	book, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	book.Source = path
+	return book, nil
+}
+
+// Parse decodes and validates an address book. It is parsed as strictly as
+// an intent. Labels and categories use the intent.ValidLabel alphabet;
+// within a chain, no two entries share a label (ignoring case) or an
+// address.
+func Parse(b []byte) (*Book, error) {
+	var f file
+	if err := intent.DecodeStrict(b, &f); err != nil {
+		return nil, err
+	}
+	if f.V != Version {
+		return nil, fmt.Errorf("/v: unsupported address book version %d (want %d)", f.V, Version)
+	}
+
+	book := &Book{}
+	for i, fe := range f.Entries {
+		path := fmt.Sprintf("/entries/%d", i)
+		e, err := fe.entry(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range book.Entries {
+			if !overlap(e, other) {
+				continue
+			}
+			if strings.EqualFold(e.Label, other.Label) {
+				return nil, fmt.Errorf("%s/label: %q is already used by another entry on the same chain", path, e.Label)
+			}
+			if e.Address == other.Address {
+				return nil, fmt.Errorf("%s/address: %s is already listed as %q", path, e.Address.Hex(), other.Label)
+			}
+		}
+		book.Entries = append(book.Entries, e)
+	}
+	return book, nil
+}
+
+func (fe *fileEntry) entry(path string) (*Entry, error) {
+	if !intent.ValidLabel(fe.Label) {
+		return nil, fmt.Errorf("%s/label: must be 1 to 64 letters, digits or . _ : -", path)
+	}
+	if fe.Category != "" && !intent.ValidLabel(fe.Category) {
+		return nil, fmt.Errorf("%s/category: must be 1 to 64 letters, digits or . _ : -", path)
+	}
+
+	s := fe.Address
+	if !strings.HasPrefix(s, "0x") || !common.IsHexAddress(s) {
+		return nil, fmt.Errorf("%s/address: invalid address %q", path, s)
+	}
+	addr := common.HexToAddress(s)
+	if digits := s[2:]; digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && addr.Hex() != s {
+		return nil, fmt.Errorf("%s/address: %q has a bad EIP-55 checksum", path, s)
+	}
+
+	e := &Entry{Label: fe.Label, Address: addr, Category: fe.Category, Deny: fe.Deny}
+	if fe.Chains != nil {
+		if len(fe.Chains) == 0 {
+			return nil, fmt.Errorf("%s/chains: must list at least one chain (omit for every chain)", path)
+		}
+		e.Chains = make(map[uint64]bool, len(fe.Chains))
+		for _, id := range fe.Chains {
+			e.Chains[id] = true
+		}
+	}
+	return e, nil
+}
+
+// overlap reports whether two entries apply to a common chain.
+func overlap(a, b *Entry) bool {
+	if a.Chains == nil || b.Chains == nil {
+		return true
+	}
+	for id := range a.Chains {
+		if b.Chains[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the entry for addr on chainID, or nil. A nil Book has no
+// entries.
+func (b *Book) Lookup(chainID uint64, addr common.Address) *Entry {
+	if b == nil {
+		return nil
+	}
+	for _, e := range b.Entries {
+		if e.Address == addr && e.onChain(chainID) {
+			return e
+		}
+	}
+	return nil
+}
+
+// Resolve returns the entry labelled label on chainID. Labels match
+// exactly.
+func (b *Book) Resolve(chainID uint64, label string) (*Entry, error) {
+	if b == nil {
+		return nil, fmt.Errorf("no address book to resolve label %q", label)
+	}
+	for _, e := range b.Entries {
+		if e.Label == label && e.onChain(chainID) {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("label %q is not in the address book for chain %d", label, chainID)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"coldsign/intent"
+)
+
+func runIntent(args []string) int {
+	if len(args) < 1 {
+		printIntentHelp()
+		return 2
+	}
+
+	switch args[0] {
+	case "sign":
+		return runIntentSign(args[1:])
+	case "new":
+		return runIntentNew(args[1:])
+	case "encode":
+		return runIntentEncode(args[1:])
+	case "decode":
+		return runIntentDecode(args[1:])
+	case "qr":
+		return runIntentQR(args[1:])
+	case "schema":
+		return runIntentSchema(args[1:])
+	case "validate":
+		return runIntentValidate(args[1:])
+	case "help", "-h", "--help":
+		printIntentHelp()
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown intent command: %s\n\n", args[0])
+		printIntentHelp()
+		return 2
+	}
+}
+
+func printIntentHelp() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  coldsign intent sign --key FILE [--cbor] [--encrypt-to KEY] [qr flags] <intent.json|->")
+	fmt.Fprintln(os.Stderr, "  coldsign intent new --out FILE")
+	fmt.Fprintln(os.Stderr, "  coldsign intent encode [--key FILE] [--cbor] [--encrypt-to KEY] [qr flags] <intent.json|->")
+	fmt.Fprintln(os.Stderr, "  coldsign intent decode [--key FILE] [--coordinators FILE] <envelope|file|->")
+	fmt.Fprintln(os.Stderr, "  coldsign intent qr [qr flags] <envelope|intent.json|->")
+	fmt.Fprintln(os.Stderr, "  coldsign intent schema [KIND]")
+	fmt.Fprintln(os.Stderr, "  coldsign intent validate <intent.json|->")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  sign      Sign an intent with a coordinator key (encode with --key required)")
+	fmt.Fprintln(os.Stderr, "  new       Build an ETH_SEND intent interactively on the cold machine")
+	fmt.Fprintln(os.Stderr, "  encode    Validate and canonicalize an intent, then print it as an envelope")
+	fmt.Fprintln(os.Stderr, "  decode    Show the canonical intent, format and origin inside an envelope")
+	fmt.Fprintln(os.Stderr, "  qr        Show an envelope (or intent) as a QR code")
+	fmt.Fprintln(os.Stderr, "  schema    Print the JSON Schema for an intent kind (no KIND: list kinds)")
+	fmt.Fprintln(os.Stderr, "  validate  Check an intent and list every problem by JSON pointer")
+}
+
+func runIntentSchema(args []string) int {
+	switch len(args) {
+	case 0:
+		for _, k := range intent.Kinds {
+			fmt.Println(k)
+		}
+		return 0
+	case 1:
+		schema, err := intent.Schema(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "schema error:", err)
+			return 2
+		}
+		os.Stdout.Write(schema)
+		return 0
+	default:
+		fmt.Fprintln(os.Stderr, "usage: coldsign intent schema [KIND]")
+		return 2
+	}
+}
+
+// runIntentValidate applies the cold machine's parsing and validation
+// (not policy) to an intent, reporting every problem found.
+func runIntentValidate(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: coldsign intent validate <intent.json|->")
+		return 2
+	}
+
+	rawInput, err := readIntentArg(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "read error:", err)
+		return 1
+	}
+	intentJSON, err := intent.DecodeEnvelopeOrJSON(string(rawInput))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "intent decode error:", err)
+		return 1
+	}
+
+	in, err := intent.Parse(intentJSON)
+	if err != nil {
+		var verr *intent.ValidationError
+		if !errors.As(err, &verr) {
+			fmt.Fprintln(os.Stderr, "intent error:", err)
+			return 1
+		}
+		for _, p := range verr.Problems {
+			fmt.Println(p)
+		}
+		fmt.Fprintf(os.Stderr, "INVALID: %d problem(s)\n", len(verr.Problems))
+		return 1
+	}
+
+	intentHash, err := in.Hash()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "intent hash error:", err)
+		return 1
+	}
+	fmt.Printf("OK: %s intent, hash %s\n", in.Kind, intentHash)
+	return 0
+}
+
+// readIntentArg reads an intent from a file, or from stdin when path is "-".
+func readIntentArg(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"coldsign/helpers"
+	"coldsign/intent"
+	"coldsign/keys"
+	"coldsign/sealed"
+)
+
+// parseIntentInput decodes and validates an intent given as JSON or an
+// envelope, with the same code the cold machine runs.
+func parseIntentInput(raw []byte) (*intent.Envelope, *intent.EthSendIntent, error) {
+	env, err := intent.DecodeEnvelope(string(raw))
+	if err != nil {
+		return nil, nil, fmt.Errorf("intent decode error: %w", err)
+	}
+	intentJSON, err := env.JSON()
+	if err != nil {
+		return nil, nil, fmt.Errorf("intent decode error: %w", err)
+	}
+	in, err := intent.Parse(intentJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("intent error: %w", err)
+	}
+	return env, in, nil
+}
+
+// runIntentEncode validates an intent, canonicalizes it and wraps it in an
+// envelope, optionally signed and sealed for the cold machine.
+func runIntentEncode(args []string) int {
+	return encodeIntent("intent encode", args, false)
+}
+
+// runIntentSign is intent encode with --key required, so intents are signed
+// one way only.
+func runIntentSign(args []string) int {
+	return encodeIntent("intent sign", args, true)
+}
+
+func encodeIntent(name string, args []string, needKey bool) int {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	keyPath := fs.String("key", "", "sign with this coordinator private key `file`")
+	asCBOR := fs.Bool("cbor", false, "carry the intent as compact CBOR instead of JSON")
+	encryptTo := fs.String("encrypt-to", "", "seal the envelope to this device X25519 public `key` (hex)")
+	qrOut := addQRFlags(fs, "envelope")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || (needKey && *keyPath == "") {
+		keyUsage := "[--key FILE]"
+		if needKey {
+			keyUsage = "--key FILE"
+		}
+		fmt.Fprintf(os.Stderr, "usage: coldsign %s %s [--cbor] [--encrypt-to KEY] [qr flags] <intent.json|->\n", name, keyUsage)
+		return 2
+	}
+	if err := qrOut.check(); err != nil {
+		fmt.Fprintln(os.Stderr, "qr error:", err)
+		return 2
+	}
+
+	var key *keys.PrivateKey
+	if *keyPath != "" {
+		var err error
+		if key, err = loadPrivateKey(*keyPath); err != nil {
+			fmt.Fprintln(os.Stderr, "key error:", err)
+			return 1
+		}
+	}
+	var recipient *ecdh.PublicKey
+	if *encryptTo != "" {
+		var err error
+		if recipient, err = sealed.ParseRecipient(*encryptTo); err != nil {
+			fmt.Fprintln(os.Stderr, "--encrypt-to:", err)
+			return 2
+		}
+	}
+
+	rawInput, err := readIntentArg(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "read error:", err)
+		return 1
+	}
+	_, in, err := parseIntentInput(rawInput)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	payload, err := in.Canonical()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "intent encode error:", err)
+		return 1
+	}
+	if *asCBOR {
+		if payload, err = intent.JSONToCBOR(payload); err != nil {
+			fmt.Fprintln(os.Stderr, "cbor error:", err)
+			return 1
+		}
+	}
+
+	env := &intent.Envelope{Payload: payload}
+	if key != nil {
+		if env, err = intent.SignIntent(in, *asCBOR, key); err != nil {
+			fmt.Fprintln(os.Stderr, "sign error:", err)
+			return 1
+		}
+	}
+
+	out := env.String()
+	if recipient != nil {
+		if out, err = sealed.Seal(recipient, []byte(out)); err != nil {
+			fmt.Fprintln(os.Stderr, "seal error:", err)
+			return 1
+		}
+	}
+
+	intentHash, err := in.Hash()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "intent hash error:", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Intent hash: %s (%s)\n", intent.ShortHash(intentHash), intentHash)
+	fmt.Println(out)
+
+	if err := qrOut.emit("INTENT QR", out); err != nil {
+		fmt.Fprintln(os.Stderr, "qr error:", err)
+		return 1
+	}
+	return 0
+}
+
+// runIntentDecode shows what an envelope carries: the canonical intent on
+// stdout, and the payload format, origin and hash on stderr.
+func runIntentDecode(args []string) int {
+	fs := flag.NewFlagSet("intent decode", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	keyPath := fs.String("key", "", "device X25519 key `file` for sealed (coldenc:v1:) input")
+	coordinatorsPath := fs.String("coordinators", "", "trusted coordinator keys `file` (default "+helpers.ConfigPath("coordinators.json")+")")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: coldsign intent decode [--key FILE] [--coordinators FILE] <envelope|file|->")
+		return 2
+	}
+
+	rawInput, err := readEnvelopeArg(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "read error:", err)
+		return 1
+	}
+
+	if sealed.IsSealed(string(rawInput)) {
+		if *keyPath == "" {
+			fmt.Fprintln(os.Stderr, "input is sealed; pass --key with the device key file")
+			return 2
+		}
+		priv, err := sealed.LoadKey(*keyPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "key error:", err)
+			return 1
+		}
+		if rawInput, err = sealed.Open(priv, string(rawInput)); err != nil {
+			fmt.Fprintln(os.Stderr, "unseal error:", err)
+			return 1
+		}
+		fmt.Fprintln(os.Stderr, "Sealed:    yes")
+	}
+
+	coordinators, err := loadCoordinators(*coordinatorsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "coordinators error:", err)
+		return 1
+	}
+
+	env, in, err := parseIntentInput(rawInput)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	format := "JSON"
+	if env.IsCBOR() {
+		format = "CBOR"
+	}
+	intentHash, err := in.Hash()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "intent hash error:", err)
+		return 1
+	}
+	canonical, err := in.Canonical()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "intent encode error:", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "Payload:   %s (%d bytes)\n", format, len(env.Payload))
+	fmt.Fprintf(os.Stderr, "Origin:    %s\n", env.Authenticate(coordinators))
+	fmt.Fprintf(os.Stderr, "Intent:    %s (%s)\n", intent.ShortHash(intentHash), intentHash)
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, canonical, "", "  "); err != nil {
+		fmt.Fprintln(os.Stderr, "intent encode error:", err)
+		return 1
+	}
+	fmt.Println(pretty.String())
+	return 0
+}
+
+// runIntentQR renders an intent as a QR code. An envelope (signed or not)
+// or sealed payload is shown exactly as given after the intent inside has
+// been validated; plain JSON is first encoded as an unsigned envelope.
+func runIntentQR(args []string) int {
+	fs := flag.NewFlagSet("intent qr", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	qrOut := addQRFlags(fs, "envelope")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: coldsign intent qr [qr flags] <envelope|intent.json|->")
+		return 2
+	}
+	if err := qrOut.check(); err != nil {
+		fmt.Fprintln(os.Stderr, "qr error:", err)
+		return 2
+	}
+	if *qrOut.png == "" && *qrOut.svg == "" {
+		*qrOut.terminal = true
+	}
+
+	rawInput, err := readEnvelopeArg(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "read error:", err)
+		return 1
+	}
+	payload := strings.TrimSpace(string(rawInput))
+
+	// A sealed payload cannot be checked here; it is shown as is.
+	if !sealed.IsSealed(payload) {
+		_, in, err := parseIntentInput(rawInput)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if !strings.HasPrefix(payload, intent.EnvelopePrefixV1) {
+			canonical, err := in.Canonical()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "intent encode error:", err)
+				return 1
+			}
+			payload = (&intent.Envelope{Payload: canonical}).String()
+		}
+	}
+
+	if err := qrOut.emit("INTENT QR", payload); err != nil {
+		fmt.Fprintln(os.Stderr, "qr error:", err)
+		return 1
+	}
+	return 0
+}
+
+// readEnvelopeArg accepts an envelope or sealed payload given inline, or
+// reads one from a file or stdin ("-").
+func readEnvelopeArg(arg string) ([]byte, error) {
+	if strings.HasPrefix(arg, intent.EnvelopePrefixV1) || sealed.IsSealed(arg) {
+		return []byte(arg), nil
+	}
+	return readIntentArg(arg)
+}
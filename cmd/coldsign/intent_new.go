@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"coldsign/hd"
+	"coldsign/helpers"
+	"coldsign/intent"
+)
+
+// runIntentNew builds an ETH_SEND intent on the cold machine from answers
+// typed at the terminal. Each answer is checked as it is entered; the
+// finished intent is validated by intent.Parse and written in canonical
+// form, to be reviewed and signed with `coldsign sign` like any other.
+func runIntentNew(args []string) int {
+	fs := flag.NewFlagSet("intent new", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	outPath := fs.String("out", "", "write the intent to this new `file`")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *outPath == "" || fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: coldsign intent new --out FILE")
+		return 2
+	}
+	if helpers.FileExists(*outPath) {
+		fmt.Fprintf(os.Stderr, "%s already exists\n", *outPath)
+		return 1
+	}
+
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, errNoTTY)
+		return 1
+	}
+	defer tty.Close()
+	p := &prompter{r: bufio.NewReader(tty)}
+
+	in := &intent.EthSendIntent{
+		V:    1,
+		Kind: intent.KindEthSend,
+		From: intent.FromRef{Type: "bip44_index"},
+	}
+
+	fmt.Fprintln(os.Stderr, helpers.Separator("NEW INTENT (ETH_SEND)"))
+	fmt.Fprintln(os.Stderr, "Press Enter to accept a [default].")
+
+	if in.ChainID, err = p.uint("Chain ID", "1", math.MaxUint64); err != nil {
+		return promptFailed(err)
+	}
+	// Non-hardened child indexes only, as used by DeriveEthKey.
+	index, err := p.uint("From index (BIP-44)", "0", math.MaxInt32)
+	if err != nil {
+		return promptFailed(err)
+	}
+	in.From.Index = uint32(index)
+
+	var seed seedInput
+	defer seed.wipe()
+	if err := seed.read(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	_, from, err := hd.DeriveEthKey(seed.mnemonic, seed.passphrase, in.From.Index)
+	seed.wipe()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "derive error:", err)
+		return 1
+	}
+	in.FromAddress = from.Hex()
+	fmt.Fprintf(os.Stderr, "From address: %s\n", in.FromAddress)
+
+	if in.To, err = p.ask("To address (0x..., EIP-55 checksum)", "", parseToAddress); err != nil {
+		return promptFailed(err)
+	}
+	if strings.EqualFold(in.To, in.FromAddress) {
+		printWarning("destination is the sending address")
+	}
+	if in.ValueWei, err = p.amount("Amount (ETH)", "", helpers.Eth); err != nil {
+		return promptFailed(err)
+	}
+	if in.Nonce, err = p.uint("Nonce", "", math.MaxUint64); err != nil {
+		return promptFailed(err)
+	}
+	if in.MaxFeePerGasWei, err = p.amount("Max fee per gas (gwei)", "", helpers.Gwei); err != nil {
+		return promptFailed(err)
+	}
+	if in.MaxPriorityFeePerGasWei, err = p.amount("Max priority fee per gas (gwei)", "", helpers.Gwei); err != nil {
+		return promptFailed(err)
+	}
+
+	// Round-trip through the parser so the file holds exactly what sign
+	// will accept.
+	canonical, err := in.Canonical()
+	if err == nil {
+		in, err = intent.Parse(canonical)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "intent error:", err)
+		return 1
+	}
+	intentHash, err := in.Hash()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "intent hash error:", err)
+		return 1
+	}
+
+	if err := helpers.WriteNewFile(*outPath, append(canonical, '\n'), 0o600); err != nil {
+		fmt.Fprintln(os.Stderr, "write error:", err)
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", *outPath)
+	fmt.Fprintf(os.Stderr, "Intent hash: %s (%s)\n", intent.ShortHash(intentHash), intentHash)
+	fmt.Fprintf(os.Stderr, "Review and sign it with: coldsign sign --sign %s\n", *outPath)
+	return 0
+}
+
+func promptFailed(err error) int {
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "canceled:", err)
+	return 1
+}
+
+// prompter asks for one value at a time on the terminal, repeating the
+// question until the answer parses.
+type prompter struct {
+	r *bufio.Reader
+}
+
+// ask prompts for label and returns parse(answer). An empty answer takes
+// def; with no default, an answer is required.
+func (p *prompter) ask(label, def string, parse func(string) (string, error)) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(os.Stderr, "%s [%s]: ", label, def)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: ", label)
+		}
+
+		line, err := p.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return "", errors.New("input closed")
+			}
+			return "", err
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if answer == "" {
+			fmt.Fprintln(os.Stderr, "  required")
+			continue
+		}
+
+		v, err := parse(answer)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "  "+err.Error())
+			continue
+		}
+		return v, nil
+	}
+}
+
+func (p *prompter) uint(label, def string, max uint64) (uint64, error) {
+	s, err := p.ask(label, def, func(s string) (string, error) {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("want a non-negative integer, got %q", s)
+		}
+		if n > max {
+			return "", fmt.Errorf("must be at most %d", max)
+		}
+		return s, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// amount reads an amount in wei. A bare number is taken in unit; any form
+// accepted by helpers.ParseWeiString may also be typed. The converted value
+// is echoed so the operator sees how it was read.
+func (p *prompter) amount(label, def string, unit helpers.Unit) (string, error) {
+	unitName := map[helpers.Unit]string{helpers.Eth: "ETH", helpers.Gwei: "gwei", helpers.Wei: "wei"}[unit]
+	return p.ask(label, def, func(s string) (string, error) {
+		if !strings.HasPrefix(s, "0x") && strings.IndexFunc(s, isLetter) < 0 {
+			s += " " + unitName
+		}
+		wei, err := helpers.ParseWeiString(s)
+		if err != nil {
+			return "", err
+		}
+		shown, err := helpers.FormatWeiString(wei, unit, 18)
+		if err != nil {
+			return "", err
+		}
+		if unit != helpers.Wei {
+			shown = strings.TrimRight(strings.TrimRight(shown, "0"), ".")
+		}
+		fmt.Fprintf(os.Stderr, "  = %s %s (%s wei)\n", shown, unitName, wei)
+		return wei, nil
+	})
+}
+
+func isLetter(r rune) bool { return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' }
+
+// parseToAddress accepts a destination with a valid EIP-55 checksum. An
+// all-lowercase or all-uppercase address carries no checksum, so a typo
+// cannot be detected; it is refused rather than checksummed here, which
+// would only bless the typo.
+func parseToAddress(s string) (string, error) {
+	if !strings.HasPrefix(s, "0x") || !common.IsHexAddress(s) {
+		return "", fmt.Errorf("not an address: %q", s)
+	}
+	addr := common.HexToAddress(s)
+	if addr == (common.Address{}) {
+		return "", errors.New("refusing the zero address")
+	}
+	digits := s[2:]
+	hasLetters := strings.ContainsAny(strings.ToLower(digits), "abcdef")
+	if hasLetters && (digits == strings.ToLower(digits) || digits == strings.ToUpper(digits)) {
+		return "", errors.New("address has no EIP-55 checksum; copy the mixed-case form from the source")
+	}
+	if addr.Hex() != s {
+		return "", errors.New("EIP-55 checksum mismatch (typo?)")
+	}
+	return s, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"coldsign/intent"
+	"coldsign/policy"
+)
+
+// outputVersion versions the --output json documents. Adding fields is
+// compatible; renaming, removing or changing the meaning of one is not and
+// must bump it.
+const outputVersion = 1
+
+// Document statuses.
+const (
+	statusSigned   = "signed"   // signed tx in txHash and rawTx/encryptedTx
+	statusReviewed = "reviewed" // reviewed and policy-checked, not signed (no --sign)
+	statusCanceled = "canceled" // operator declined at the confirmation prompt
+	statusRefused  = "refused"  // invalid intent, policy violation or nonce conflict
+	statusOK       = "ok"       // addr: address derived; policy check: every check passed or warned
+	statusError    = "error"    // anything else; see error.code
+)
+
+// codeInput marks failures to read the input before any intent is decoded.
+const codeInput = "input_failed"
+
+func addOutputFlag(fs *flag.FlagSet) *string {
+	return fs.String("output", "text", "stdout `format`: text or json (with json, human-readable text goes to stderr)")
+}
+
+// jsonOutput reports whether --output selects JSON, rejecting unknown
+// formats.
+func jsonOutput(format string) (bool, error) {
+	switch format {
+	case "text":
+		return false, nil
+	case "json":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid --output %q: want text or json", format)
+	}
+}
+
+type jsonError struct {
+	Code     string           `json:"code"`
+	Message  string           `json:"message"`
+	Problems []intent.Problem `json:"problems,omitempty"` // intent_invalid only
+}
+
+func newJSONError(code string, err error) *jsonError {
+	e := &jsonError{Code: code, Message: err.Error()}
+	var verr *intent.ValidationError
+	if errors.As(err, &verr) {
+		e.Problems = verr.Problems
+	}
+	return e
+}
+
+type jsonReviewField struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+type jsonPolicy struct {
+	OK        bool        `json:"ok"`
+	Hash      string      `json:"hash"`
+	Source    string      `json:"source"` // policy file path or "built-in defaults"
+	Version   uint64      `json:"version"`
+	Signers   []string    `json:"signers,omitempty"`   // administrators who signed it
+	Role      string      `json:"role,omitempty"`      // the sender's account role, if it has one
+	Overrides []string    `json:"overrides,omitempty"` // command-line overrides applied to it
+	Warnings  []string    `json:"warnings,omitempty"`
+	Checks    []jsonCheck `json:"checks,omitempty"`
+	Limits    []jsonLimit `json:"limits,omitempty"`
+	Rules     []jsonRule  `json:"rules,omitempty"`
+}
+
+// jsonCheck is the outcome of one policy check (see policy.Check).
+type jsonCheck struct {
+	Name    string `json:"name"`
+	Result  string `json:"result"` // pass, warn or fail
+	Actual  string `json:"actual"`
+	Limit   string `json:"limit"`
+	Message string `json:"message,omitempty"`
+}
+
+// jsonRule is the result of one policy rule.
+type jsonRule struct {
+	Name   string `json:"name"`
+	Action string `json:"action"` // refuse or warn
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"` // evaluation failed
+}
+
+// jsonLimit is a rolling limit and the sender's usage. Amounts are wei, or
+// token base units.
+type jsonLimit struct {
+	Asset  string `json:"asset"`
+	Window string `json:"window"`
+	Max    string `json:"max"`
+	Spent  string `json:"spent"` // before this transaction
+	After  string `json:"after"` // with this transaction
+}
+
+// signDocument is the --output json result of reviewing one intent.
+type signDocument struct {
+	V           int               `json:"v"`
+	Command     string            `json:"command"`
+	Status      string            `json:"status"`
+	ExitCode    int               `json:"exitCode"`
+	Error       *jsonError        `json:"error,omitempty"`
+	IntentID    string            `json:"intentId,omitempty"`
+	IntentHash  string            `json:"intentHash,omitempty"`
+	Intent      json.RawMessage   `json:"intent,omitempty"` // canonical form
+	Review      []jsonReviewField `json:"review,omitempty"`
+	Policy      *jsonPolicy       `json:"policy,omitempty"` // absent if the review was not reached
+	TxHash      string            `json:"txHash,omitempty"`
+	RawTx       string            `json:"rawTx,omitempty"`
+	EncryptedTx string            `json:"encryptedTx,omitempty"`
+}
+
+// newSignDocument describes the outcome of signIntent. out may be nil or
+// partial when err is set.
+func newSignDocument(out *signOutcome, err error, exitCode int, opts *signOptions) *signDocument {
+	doc := &signDocument{V: outputVersion, Command: "sign", ExitCode: exitCode}
+
+	code := ""
+	if err != nil {
+		code = errorCode(err)
+		doc.Error = newJSONError(code, err)
+	}
+	switch code {
+	case "":
+		doc.Status = statusSigned
+	case codeNotAuthorized:
+		doc.Status = statusReviewed
+		doc.Error = nil
+	case codeCanceled:
+		doc.Status = statusCanceled
+	case codeIntentInvalid, codePolicy, codeNonce:
+		doc.Status = statusRefused
+	default:
+		doc.Status = statusError
+	}
+
+	if out == nil {
+		return doc
+	}
+	if out.intent != nil {
+		doc.IntentID = out.intent.IntentID
+		doc.IntentHash = out.intentHash
+		doc.Intent = out.canonical
+	}
+	if out.review != nil {
+		for _, f := range out.review.fields {
+			doc.Review = append(doc.Review, jsonReviewField{Label: f.label, Value: f.value})
+		}
+		doc.Policy = newJSONPolicy(opts.pol, out.eval, out.policyOK, out.warnings)
+	}
+	if out.signed != nil && out.output != "" {
+		doc.TxHash = out.signed.TxHash
+		if opts.outputRecipient != nil {
+			doc.EncryptedTx = out.output
+		} else {
+			doc.RawTx = out.output
+		}
+	}
+	return doc
+}
+
+// newJSONPolicy describes the policy in force and its checks on one
+// intent. ev may be nil if the checks were not reached.
+func newJSONPolicy(pol *policy.Policy, ev *policy.Evaluation, ok bool, warnings []string) *jsonPolicy {
+	jp := &jsonPolicy{
+		OK:        ok,
+		Hash:      pol.Hash(),
+		Source:    pol.Source,
+		Version:   pol.Version,
+		Signers:   pol.Signers,
+		Overrides: pol.Overrides,
+		Warnings:  warnings,
+	}
+	if ev == nil {
+		return jp
+	}
+	if ev.Account != nil {
+		jp.Role = ev.Account.Role
+	}
+	for _, c := range ev.Checks {
+		jp.Checks = append(jp.Checks, jsonCheck{Name: c.Name, Result: c.Result, Actual: c.Actual, Limit: c.Limit, Message: c.Message})
+	}
+	for _, res := range ev.Rules {
+		jr := jsonRule{Name: res.Rule.Name, Action: res.Rule.Action, Passed: res.Passed}
+		if res.Err != nil {
+			jr.Error = res.Err.Error()
+		}
+		jp.Rules = append(jp.Rules, jr)
+	}
+	for _, u := range ev.Usage {
+		jp.Limits = append(jp.Limits, jsonLimit{
+			Asset: u.Asset, Window: u.Window, Max: u.Max.String(), Spent: u.Spent.String(), After: u.After.String(),
+		})
+	}
+	return jp
+}
+
+// policyCheckDocument is the --output json result of policy check.
+type policyCheckDocument struct {
+	V          int             `json:"v"`
+	Command    string          `json:"command"`
+	Status     string          `json:"status"`
+	ExitCode   int             `json:"exitCode"`
+	Error      *jsonError      `json:"error,omitempty"`
+	IntentID   string          `json:"intentId,omitempty"`
+	IntentHash string          `json:"intentHash,omitempty"`
+	Intent     json.RawMessage `json:"intent,omitempty"` // canonical form
+	Policy     *jsonPolicy     `json:"policy,omitempty"` // absent if the intent could not be read
+}
+
+// addrDocument is the --output json result of addr.
+type addrDocument struct {
+	V        int        `json:"v"`
+	Command  string     `json:"command"`
+	Status   string     `json:"status"`
+	ExitCode int        `json:"exitCode"`
+	Error    *jsonError `json:"error,omitempty"`
+	Index    uint32     `json:"index"`
+	Address  string     `json:"address,omitempty"`
+	URI      string     `json:"uri,omitempty"`
+}
+
+// writeJSONDocument writes v to stdout as a single line.
+func writeJSONDocument(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, "output error:", err)
+	}
+}
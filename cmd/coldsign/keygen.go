@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"coldsign/intent"
+	"coldsign/keys"
+	"coldsign/sealed"
+)
+
+func runKeygen(args []string) int {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	alg := fs.String("alg", keys.AlgEd25519, "key algorithm: ed25519, secp256k1 (signing) or x25519 (encryption)")
+	out := fs.String("out", "", "private key output `file` (must not exist)")
+	fromSeed := fs.Bool("from-seed", false, "x25519 only: print the seed-derived device public key")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *alg == "x25519" {
+		return runKeygenX25519(*out, *fromSeed)
+	}
+
+	if *out == "" || *fromSeed {
+		fmt.Fprintln(os.Stderr, "usage: coldsign keygen --alg ed25519|secp256k1 --out FILE")
+		return 2
+	}
+
+	key, err := keys.Generate(*alg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "keygen error:", err)
+		return 1
+	}
+	if err := key.Save(*out); err != nil {
+		fmt.Fprintln(os.Stderr, "keygen error:", err)
+		return 1
+	}
+
+	pub := key.Public()
+	fmt.Fprintln(os.Stderr, "Private key written:", *out)
+	fmt.Fprintln(os.Stderr, "Keep it off the cold machine; copy only the public key there (coordinators.json or admins.json).")
+	fmt.Printf("{\"name\": \"CHANGE-ME\", \"alg\": %q, \"publicKey\": %q}\n", pub.Alg, pub.Hex())
+	return 0
+}
+
+// runKeygenX25519 creates (or derives) a transport encryption key and
+// prints its public half, which the other side passes to `seal --to`.
+func runKeygenX25519(out string, fromSeed bool) int {
+	if (out == "") == !fromSeed {
+		fmt.Fprintln(os.Stderr, "usage: coldsign keygen --alg x25519 (--out FILE | --from-seed)")
+		return 2
+	}
+
+	if fromSeed {
+		var seed seedInput
+		defer seed.wipe()
+
+		priv, _, err := seed.deviceKey("")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "keygen error:", err)
+			return 1
+		}
+		fmt.Println(hex.EncodeToString(priv.PublicKey().Bytes()))
+		return 0
+	}
+
+	priv, err := sealed.GenerateKey()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "keygen error:", err)
+		return 1
+	}
+	if err := sealed.SaveKey(out, priv); err != nil {
+		fmt.Fprintln(os.Stderr, "keygen error:", err)
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, "Private key written:", out)
+	fmt.Println(hex.EncodeToString(priv.PublicKey().Bytes()))
+	return 0
+}
+
+// loadPrivateKey reads a signing key written by keygen. The file is parsed
+// as strictly as an intent.
+func loadPrivateKey(path string) (*keys.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var kf keys.KeyFile
+	if err := intent.DecodeStrict(b, &kf); err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	key, err := kf.Key()
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	return key, nil
+}
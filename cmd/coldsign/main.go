@@ -1,24 +1,15 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
-	"io"
 	"math/big"
 	"os"
-	"strings"
 
 	"coldsign/hd"
 	"coldsign/helpers"
 	"coldsign/intent"
 	"coldsign/logo"
-	"coldsign/policy"
-	"coldsign/qr"
-	"coldsign/signer"
-	"coldsign/tx"
-
-	"github.com/ethereum/go-ethereum/common"
 )
 
 var Version = "dev"
@@ -42,6 +33,18 @@ func main() {
 		os.Exit(runSign(os.Args[2:]))
 	case "addr":
 		os.Exit(runAddr(os.Args[2:]))
+	case "process":
+		os.Exit(runProcess(os.Args[2:]))
+	case "intent":
+		os.Exit(runIntent(os.Args[2:]))
+	case "policy":
+		os.Exit(runPolicy(os.Args[2:]))
+	case "keygen":
+		os.Exit(runKeygen(os.Args[2:]))
+	case "seal":
+		os.Exit(runSeal(os.Args[2:]))
+	case "unseal":
+		os.Exit(runUnseal(os.Args[2:]))
 	default:
 		// Backward compatibility: coldsign <intent.json>
 		if helpers.FileExists(cmd) {
@@ -58,13 +61,31 @@ func printHelp() {
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Usage:")
 	fmt.Fprintln(os.Stderr, "  coldsign sign [flags] <intent.json>")
-	fmt.Fprintln(os.Stderr, "  coldsign addr --index N [--qr]")
+	fmt.Fprintln(os.Stderr, "  coldsign process --inbox DIR --outbox DIR [flags]")
+	fmt.Fprintln(os.Stderr, "  coldsign addr --index N [--uri [--chain-id N]] [--qr] [--qr-png FILE] [--qr-svg FILE]")
+	fmt.Fprintln(os.Stderr, "  coldsign intent sign --key FILE [--cbor] <intent.json>")
+	fmt.Fprintln(os.Stderr, "  coldsign intent new --out FILE")
+	fmt.Fprintln(os.Stderr, "  coldsign intent encode|decode|qr [flags] <input>")
+	fmt.Fprintln(os.Stderr, "  coldsign intent schema [KIND]")
+	fmt.Fprintln(os.Stderr, "  coldsign intent validate <intent.json>")
+	fmt.Fprintln(os.Stderr, "  coldsign policy sign --key FILE <policy.json>")
+	fmt.Fprintln(os.Stderr, "  coldsign policy check [flags] <intent.json|->")
+	fmt.Fprintln(os.Stderr, "  coldsign keygen --alg ed25519|secp256k1|x25519 --out FILE")
+	fmt.Fprintln(os.Stderr, "  coldsign keygen --alg x25519 --from-seed")
+	fmt.Fprintln(os.Stderr, "  coldsign seal --to KEY <file|->")
+	fmt.Fprintln(os.Stderr, "  coldsign unseal [--key FILE] <file|->")
 	fmt.Fprintln(os.Stderr, "  coldsign help")
 	fmt.Fprintln(os.Stderr, "  coldsign version")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Commands:")
 	fmt.Fprintln(os.Stderr, "  sign     Review and sign transaction intents")
+	fmt.Fprintln(os.Stderr, "  process  Review and sign every intent file in an inbox directory")
 	fmt.Fprintln(os.Stderr, "  addr     Derive and display Ethereum addresses")
+	fmt.Fprintln(os.Stderr, "  intent   Build, encode and inspect intents")
+	fmt.Fprintln(os.Stderr, "  policy   Sign policy files; check intents against the policy")
+	fmt.Fprintln(os.Stderr, "  keygen   Generate a coordinator or administrator signing key, or a device encryption key")
+	fmt.Fprintln(os.Stderr, "  seal     Encrypt an intent or signed tx to an X25519 public key")
+	fmt.Fprintln(os.Stderr, "  unseal   Decrypt a coldenc:v1: payload")
 	fmt.Fprintln(os.Stderr, "  help     Show this help message")
 	fmt.Fprintln(os.Stderr, "  version  Show version information")
 }
@@ -73,254 +94,77 @@ func printVersion() {
 	fmt.Println("coldsign", Version)
 }
 
-func runSign(args []string) int {
-	fs := flag.NewFlagSet("sign", flag.ContinueOnError)
+func runAddr(args []string) int {
+	fs := flag.NewFlagSet("addr", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 
-	qrFlag := fs.Bool("qr", false, "print signed raw tx as terminal QR (to stderr)")
-	intentStdin := fs.Bool("intent-stdin", false, "read intent from stdin (JSON or coldintent:v1:<base64url>)")
-	signFlag := fs.Bool("sign", false, "authorize signing (otherwise only review)")
-	yesFlag := fs.Bool("yes", false, "skip interactive confirmation (DANGEROUS)")
+	index := fs.Int("index", -1, "BIP-44 address index")
+	asURI := fs.Bool("uri", false, "print an EIP-681 ethereum: receive URI instead of the bare address")
+	chainID := fs.Uint64("chain-id", 1, "chain id for --uri (0 to omit)")
+	output := addOutputFlag(fs)
+	qrOut := addQRFlags(fs, "address")
 
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
-
-	var rawInput []byte
-	var err error
-
-	if *intentStdin {
-		fmt.Fprintln(os.Stderr, "READY: waiting for intent on stdin (JSON or coldintent:v1:...)")
-		fmt.Fprintln(os.Stderr, "Tip: zbarcam --raw | coldsign sign --intent-stdin ...")
-
-		reader := bufio.NewReader(os.Stdin)
-		line, readErr := reader.ReadString('\n')
-		if readErr != nil && readErr != io.EOF {
-			fmt.Fprintln(os.Stderr, "stdin read error:", readErr)
-			return 1
-		}
-		rawInput = []byte(strings.TrimSpace(line))
-		if len(rawInput) == 0 {
-			fmt.Fprintln(os.Stderr, "stdin error: no intent provided")
-			return 1
-		}
-	} else {
-		if fs.NArg() != 1 {
-			fmt.Fprintln(os.Stderr, "usage: coldsign sign [flags] <intent.json>")
-			return 2
-		}
-		rawInput, err = os.ReadFile(fs.Arg(0))
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "read error:", err)
-			return 1
-		}
-	}
-
-	decodedJSON, err := intent.DecodeEnvelopeOrJSON(string(rawInput))
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "intent decode error:", err)
-		return 1
-	}
-
-	in, err := intent.ParseEthSend(decodedJSON)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "intent error:", err)
-		return 1
-	}
-
-	// Validate addresses before proceeding
-	if !common.IsHexAddress(in.To) {
-		fmt.Fprintln(os.Stderr, "intent error: invalid to address")
-		return 1
-	}
-	if !common.IsHexAddress(in.FromAddress) {
-		fmt.Fprintln(os.Stderr, "intent error: invalid fromAddress")
-		return 1
-	}
-
-	fmt.Println("")
-	fmt.Println(helpers.Separator("SIGNING REVIEW (ETH_SEND)"))
-	fmt.Printf("Chain:   %d\n", in.ChainID)
-	fmt.Printf("From:    %s\n", in.FromAddress)
-	fmt.Printf("To:      %s\n", in.To)
-	fmt.Printf("Nonce:   %d\n", in.Nonce)
-
-	amtEth, err := helpers.FormatETH(in.ValueWei)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "invalid valueWei:", err)
-		return 1
-	}
-	fmt.Printf("Amount:  %s ETH  (%s wei)\n", amtEth, in.ValueWei)
-
-	maxGwei, err := helpers.FormatGwei(in.MaxFeePerGasWei)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "invalid maxFeePerGasWei:", err)
-		return 1
-	}
-	tipGwei, err := helpers.FormatGwei(in.MaxPriorityFeePerGasWei)
+	asJSON, err := jsonOutput(*output)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "invalid maxPriorityFeePerGasWei:", err)
-		return 1
-	}
-	fmt.Printf("Fees:    max=%s gwei, tip=%s gwei\n", maxGwei, tipGwei)
-
-	// Worst-case: 21000 * maxFeePerGasWei
-	mfWei, ok := new(big.Int).SetString(in.MaxFeePerGasWei, 10)
-	if !ok {
-		fmt.Fprintln(os.Stderr, "invalid maxFeePerGasWei:", in.MaxFeePerGasWei)
-		return 1
-	}
-
-	worstWei := new(big.Int).Mul(big.NewInt(21000), mfWei)
-	worstEth, err := helpers.FormatETH6(worstWei.String())
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "fee cap format error:", err)
-		return 1
+		fmt.Fprintln(os.Stderr, err)
+		return 2
 	}
-
-	fmt.Printf("Fee cap: ~%s ETH worst-case\n", worstEth)
-
-	fmt.Println(helpers.Separator(""))
-
-	if err := policy.Default().Enforce(in); err != nil {
-		fmt.Fprintln(os.Stderr, "policy violation:", err)
-		return 1
+	if err := qrOut.check(); err != nil {
+		fmt.Fprintln(os.Stderr, "qr error:", err)
+		return 2
 	}
-
-	fmt.Println("Policy check: OK")
-
-	if !*signFlag {
-		fmt.Println("NOT SIGNED: pass --sign to authorize signing")
+	if *index < 0 {
+		fmt.Fprintln(os.Stderr, "usage: coldsign addr --index N [--uri [--chain-id N]] [--qr] [--qr-png FILE] [--qr-svg FILE]")
 		return 2
 	}
 
-	if !*yesFlag {
-		tty, err := os.Open("/dev/tty")
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "no TTY available; re-run with --yes")
-			return 2
-		}
-		defer tty.Close()
-
-		to := common.HexToAddress(in.To).Hex()
-		// Generate confirmation code once and reuse for display and validation
-		first := strings.ToLower(to[2:6])
-		last := strings.ToLower(to[len(to)-4:])
-		code := fmt.Sprintf("%s %s", first, last)
-
-		fmt.Fprintln(os.Stderr, "")
-		fmt.Fprintln(os.Stderr, helpers.Separator("CONFIRM SIGNING"))
-		fmt.Fprintln(os.Stderr, "Destination address:")
-		fmt.Fprintln(os.Stderr, to)
-		fmt.Fprintln(os.Stderr, "")
-		fmt.Fprintln(os.Stderr, "Re-type the destination address fragment exactly as shown:")
-		fmt.Fprintln(os.Stderr, code)
-		fmt.Fprint(os.Stderr, "> ")
-
-		resp, _ := bufio.NewReader(tty).ReadString('\n')
-		got := strings.Fields(strings.ToLower(resp))
-		want := strings.Fields(code) // ["1111","1111"]
-		if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
-			fmt.Fprintln(os.Stderr, "Canceled.")
-			return 0
+	doc := addrDocument{V: outputVersion, Command: "addr", Status: statusOK, Index: uint32(*index)}
+	fail := func(code string, err error) int {
+		fmt.Fprintln(os.Stderr, err)
+		if asJSON {
+			doc.Status, doc.ExitCode, doc.Error = statusError, 1, newJSONError(code, err)
+			writeJSONDocument(doc)
 		}
-	}
-
-	mnemonic, err := helpers.ReadHiddenLineFromTTY(
-		"ENTER MNEMONIC (space-separated BIP-39 words; hidden)",
-		false,
-	)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "mnemonic error:", err)
-		return 1
-	}
-	defer helpers.ZeroString(&mnemonic)
-
-	passphrase, err := helpers.ReadHiddenLineFromTTY(
-		"ENTER PASSPHRASE (optional; hidden)",
-		true,
-	)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "passphrase error:", err)
-		return 1
-	}
-	defer helpers.ZeroString(&passphrase)
-
-	privKey, addr, err := hd.DeriveEthKey(mnemonic, passphrase, in.From.Index)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "hd derive error:", err)
-		return 1
-	}
-
-	if addr.Hex() != common.HexToAddress(in.FromAddress).Hex() {
-		fmt.Fprintln(os.Stderr, "fromAddress mismatch")
-		return 1
-	} else {
-		fmt.Println("From address verified:", addr.Hex())
-	}
-
-	unsignedTx, err := tx.BuildUnsignedEthSendTx(in)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "tx build error:", err)
-		return 1
-	}
-
-	signed, err := signer.SignEIP1559Tx(unsignedTx, in.ChainID, privKey)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "sign error:", err)
 		return 1
 	}
 
-	fmt.Println(helpers.Separator(""))
-	fmt.Println("Signed tx hash:", signed.TxHash)
-	fmt.Println("Signed raw tx hex:", signed.RawTxHex)
-
-	if *qrFlag {
-		fmt.Fprintln(os.Stderr, "\n--- SIGNED RAW TX QR ---")
-		qr.PrintToTerminal(signed.RawTxHex)
-	}
-
-	fmt.Println("DONE: signed transaction ready for broadcast")
-	return 0
-}
-
-func runAddr(args []string) int {
-	fs := flag.NewFlagSet("addr", flag.ContinueOnError)
-	fs.SetOutput(os.Stderr)
-
-	index := fs.Int("index", -1, "BIP-44 address index")
-	qrFlag := fs.Bool("qr", false, "print address as terminal QR")
-
-	if err := fs.Parse(args); err != nil {
-		return 2
-	}
-	if *index < 0 {
-		fmt.Fprintln(os.Stderr, "usage: coldsign addr --index N [--qr]")
-		return 2
-	}
-
 	mnemonic, err := helpers.ReadHiddenLineFromTTY("ENTER MNEMONIC (hidden)", false)
 	if err != nil {
-		return 1
+		return fail(codeSeed, fmt.Errorf("mnemonic error: %w", err))
 	}
 	defer helpers.ZeroString(&mnemonic)
 
 	passphrase, err := helpers.ReadHiddenLineFromTTY("ENTER PASSPHRASE (optional)", true)
 	if err != nil {
-		return 1
+		return fail(codeSeed, fmt.Errorf("passphrase error: %w", err))
 	}
 	defer helpers.ZeroString(&passphrase)
 
 	_, addr, err := hd.DeriveEthKey(mnemonic, passphrase, uint32(*index))
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "derive error:", err)
-		return 1
+		return fail(codeDerive, fmt.Errorf("derive error: %w", err))
+	}
+
+	out := addr.Hex()
+	doc.Address = addr.Hex()
+	if *asURI {
+		out = intent.ReceiveURI(addr, *chainID)
+		doc.URI = out
 	}
 
-	fmt.Println(addr.Hex())
-	if *qrFlag {
-		fmt.Fprintln(os.Stderr, "\n--- ADDRESS QR ---")
-		qr.PrintToTerminal(addr.Hex())
+	if asJSON {
+		fmt.Fprintln(os.Stderr, out)
+	} else {
+		fmt.Println(out)
+	}
+	if err := qrOut.emit("ADDRESS QR", out); err != nil {
+		return fail(codeOutput, fmt.Errorf("qr error: %w", err))
+	}
+	if asJSON {
+		writeJSONDocument(doc)
 	}
 	return 0
 }
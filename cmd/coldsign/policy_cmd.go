@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"coldsign/helpers"
+	"coldsign/intent"
+	"coldsign/policy"
+)
+
+func runPolicy(args []string) int {
+	if len(args) < 1 {
+		printPolicyHelp()
+		return 2
+	}
+
+	switch args[0] {
+	case "sign":
+		return runPolicySign(args[1:])
+	case "check":
+		return runPolicyCheck(args[1:])
+	case "help", "-h", "--help":
+		printPolicyHelp()
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown policy command: %s\n\n", args[0])
+		printPolicyHelp()
+		return 2
+	}
+}
+
+func printPolicyHelp() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  coldsign policy sign --key FILE [--sig FILE] <policy.json>")
+	fmt.Fprintln(os.Stderr, "  coldsign policy check [flags] <intent.json|->")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  sign   Add an administrator signature to a policy's signature file")
+	fmt.Fprintln(os.Stderr, "  check  Run every policy check on an intent and report each, without signing")
+}
+
+// runPolicySign adds one administrator's signature to the policy's
+// detached signature file. Administrators sign in turn until the
+// threshold is met; signatures for an earlier revision are dropped.
+func runPolicySign(args []string) int {
+	fs := flag.NewFlagSet("policy sign", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	keyPath := fs.String("key", "", "administrator private key `file` (from coldsign keygen)")
+	sigPath := fs.String("sig", "", "signature `file` (default: the policy file + .sig)")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *keyPath == "" || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: coldsign policy sign --key FILE [--sig FILE] <policy.json>")
+		return 2
+	}
+	path := fs.Arg(0)
+	if *sigPath == "" {
+		*sigPath = policy.SignaturePath(path)
+	}
+
+	key, err := loadPrivateKey(*keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "key error:", err)
+		return 1
+	}
+	pol, err := policy.Load(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "policy error:", err)
+		return 1
+	}
+	hash := pol.Hash()
+
+	sigs := &policy.Signatures{V: policy.SignatureVersion, PolicyHash: hash}
+	if helpers.FileExists(*sigPath) {
+		old, err := policy.LoadSignatures(*sigPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "signature file error:", err)
+			return 1
+		}
+		if old.PolicyHash == hash {
+			sigs = old
+		} else {
+			fmt.Fprintf(os.Stderr, "Dropping %d signature(s) for an earlier revision (%s)\n",
+				len(old.Signatures), intent.ShortHash(old.PolicyHash))
+		}
+	}
+
+	if err := pol.Sign(sigs, key); err != nil {
+		fmt.Fprintln(os.Stderr, "sign error:", err)
+		return 1
+	}
+	if err := sigs.Save(*sigPath); err != nil {
+		fmt.Fprintln(os.Stderr, "write error:", err)
+		return 1
+	}
+
+	pub := key.Public()
+	fmt.Fprintf(os.Stderr, "Signed policy version %d (%s) with %s %s\n", pol.Version, intent.ShortHash(hash), pub.Alg, pub.Fingerprint())
+	fmt.Fprintf(os.Stderr, "%s now holds %d signature(s)\n", *sigPath, len(sigs.Signatures))
+	return 0
+}
+
+// runPolicyCheck runs every policy check on one intent and reports each
+// with what the intent has and what the policy allows. It judges the
+// intent exactly as sign does (see reviewIntent), but signs nothing and
+// records nothing; the seed is read only to decrypt an encrypted intent
+// without --decrypt-key. It exits 0 if the policy allows the intent and 1
+// if it refuses it.
+func runPolicyCheck(args []string) int {
+	fs := flag.NewFlagSet("policy check", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	rf := addReviewFlags(fs)
+	output := addOutputFlag(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: coldsign policy check [flags] <intent.json|->")
+		return 2
+	}
+	asJSON, err := jsonOutput(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	opts, code := rf.options(false)
+	if opts == nil {
+		return code
+	}
+	if asJSON {
+		opts.human = os.Stderr
+	}
+
+	var raw []byte
+	if path := fs.Arg(0); path == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(path)
+	}
+	if err != nil {
+		err = failf(codeInput, "read error: %w", err)
+	}
+
+	var seed seedInput
+	defer seed.wipe()
+
+	var out *signOutcome
+	if err == nil {
+		out, err = reviewIntent(bytes.TrimSpace(raw), opts, &seed, "POLICY CHECK")
+	}
+
+	doc := &policyCheckDocument{V: outputVersion, Command: "policy check", Status: statusOK}
+	if out != nil {
+		doc.IntentID = out.intent.IntentID
+		doc.IntentHash = out.intentHash
+		doc.Intent = out.canonical
+	}
+	if out != nil && out.eval != nil {
+		doc.Policy = newJSONPolicy(opts.pol, out.eval, out.policyOK, out.warnings)
+	}
+
+	switch code := errorCode(err); {
+	case err == nil:
+	case code == codePolicy:
+		fmt.Fprintf(opts.human, "Policy check: REFUSED (%d of %d checks failed)\n", len(out.eval.Failed()), len(out.eval.Checks))
+		fmt.Fprintln(os.Stderr, err)
+		doc.ExitCode, doc.Status = 1, statusRefused
+	case code == codeIntentInvalid, code == codeNonce:
+		fmt.Fprintln(os.Stderr, err)
+		doc.ExitCode, doc.Status = 1, statusRefused
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		doc.ExitCode, doc.Status = 1, statusError
+	}
+	if err != nil {
+		doc.Error = newJSONError(errorCode(err), err)
+	}
+	if asJSON {
+		writeJSONDocument(doc)
+	}
+	return doc.ExitCode
+}
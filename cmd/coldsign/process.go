@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"coldsign/helpers"
+)
+
+// archiveDir is the inbox subfolder that receives processed intent files.
+const archiveDir = "archive"
+
+// signedOutput is written to the outbox for every signed intent.
+type signedOutput struct {
+	Source      string `json:"source"`
+	IntentID    string `json:"intentId,omitempty"`
+	IntentHash  string `json:"intentHash"`
+	TxHash      string `json:"txHash"`
+	RawTx       string `json:"rawTx,omitempty"`
+	EncryptedTx string `json:"encryptedTx,omitempty"`
+	SignedAt    string `json:"signedAt"`
+}
+
+// rejectionReport is written to the outbox for every refused intent.
+type rejectionReport struct {
+	Source     string `json:"source"`
+	IntentID   string `json:"intentId,omitempty"`
+	IntentHash string `json:"intentHash,omitempty"` // empty if the intent could not be parsed
+	Error      string `json:"error"`
+	RejectedAt string `json:"rejectedAt"`
+}
+
+func runProcess(args []string) int {
+	fs := flag.NewFlagSet("process", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	inbox := fs.String("inbox", "", "`directory` of pending intent files")
+	outbox := fs.String("outbox", "", "`directory` for signed outputs and rejection reports")
+	sf := addSignFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *inbox == "" || *outbox == "" || fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: coldsign process --inbox DIR --outbox DIR [flags]")
+		return 2
+	}
+
+	opts, code := sf.options(nil)
+	if opts == nil {
+		return code
+	}
+
+	pending, err := pendingIntents(*inbox)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "inbox error:", err)
+		return 1
+	}
+	if err := os.MkdirAll(*outbox, 0o700); err != nil {
+		fmt.Fprintln(os.Stderr, "outbox error:", err)
+		return 1
+	}
+	if len(pending) == 0 {
+		fmt.Fprintln(os.Stderr, "No pending intents in", *inbox)
+		return 0
+	}
+
+	var seed seedInput
+	defer seed.wipe()
+
+	var signed, rejected, skipped int
+	for i, name := range pending {
+		fmt.Println("")
+		fmt.Println(helpers.Separator(fmt.Sprintf("FILE %d/%d: %s", i+1, len(pending), name)))
+
+		result, err := processFile(*inbox, *outbox, name, opts, &seed)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		if result == processFailed {
+			fmt.Fprintf(os.Stderr, "Stopped at %s: it and any later files stay in the inbox\n", name)
+			skipped += len(pending) - i
+			break
+		}
+		switch result {
+		case processSigned:
+			signed++
+		case processRejected:
+			rejected++
+		default:
+			skipped++
+		}
+	}
+
+	fmt.Println("")
+	fmt.Println(helpers.Separator("PROCESS SUMMARY"))
+	fmt.Printf("Files:   %d signed, %d rejected, %d left in inbox\n", signed, rejected, skipped)
+
+	if rejected > 0 || skipped > 0 {
+		return 1
+	}
+	return 0
+}
+
+type processResult int
+
+const (
+	processSkipped  processResult = iota // left in the inbox untouched
+	processSigned                        // output written, input archived
+	processRejected                      // report written, input archived
+	processFailed                        // operator-side fault; input left in the inbox, run stops
+)
+
+// processFile reviews and signs one inbox file, writes its output or
+// rejection report, and archives it. Existing outputs are never
+// overwritten: if one is already present the file is left in the inbox.
+// Only a decision about the intent is reported as a rejection (see
+// rejectable); any other failure, such as a wrong seed or an unwritable
+// outbox, leaves the file in the inbox and stops the run.
+func processFile(inbox, outbox, name string, opts *signOptions, seed *seedInput) (processResult, error) {
+	// Outputs keep the whole input name, so pay.json and pay.txt cannot
+	// collide.
+	signedPath := filepath.Join(outbox, name+".signed.json")
+	rejectedPath := filepath.Join(outbox, name+".rejected.json")
+
+	for _, p := range []string{signedPath, rejectedPath} {
+		if helpers.FileExists(p) {
+			return processSkipped, fmt.Errorf("skipped %s: output %s already exists", name, p)
+		}
+	}
+
+	raw, err := os.ReadFile(filepath.Join(inbox, name))
+	if err != nil {
+		return processSkipped, fmt.Errorf("skipped %s: %w", name, err)
+	}
+
+	out, signErr := signIntent(raw, opts, seed)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	switch {
+	case signErr == nil:
+		doc := signedOutput{
+			Source:     name,
+			IntentID:   out.intent.IntentID,
+			IntentHash: out.intentHash,
+			TxHash:     out.signed.TxHash,
+			SignedAt:   now,
+		}
+		if opts.outputRecipient != nil {
+			doc.EncryptedTx = out.output
+		} else {
+			doc.RawTx = out.output
+		}
+		if err := writeNewJSON(signedPath, doc); err != nil {
+			return processFailed, fmt.Errorf("%s signed but output not written: %w", name, err)
+		}
+		fmt.Println("Output written:", signedPath)
+
+	case errors.Is(signErr, errNotAuthorized), errors.Is(signErr, errNoTTY):
+		// Review only: nothing was decided, so leave the file pending.
+		signExitCode(opts.human, signErr)
+		return processSkipped, nil
+
+	case !rejectable(signErr):
+		signExitCode(opts.human, signErr)
+		return processFailed, nil
+
+	default:
+		signExitCode(opts.human, signErr)
+		reason := signErr.Error()
+		if errors.Is(signErr, errCanceled) {
+			reason = "canceled by operator"
+		}
+		doc := rejectionReport{Source: name, Error: reason, RejectedAt: now}
+		if out != nil {
+			doc.IntentID = out.intent.IntentID
+			doc.IntentHash = out.intentHash
+		}
+		if err := writeNewJSON(rejectedPath, doc); err != nil {
+			return processFailed, fmt.Errorf("%s rejected but report not written: %w", name, err)
+		}
+		fmt.Println("Rejection written:", rejectedPath)
+	}
+
+	archived, err := archiveFile(inbox, name)
+	if err != nil {
+		return processFailed, fmt.Errorf("%s processed but not archived: %w", name, err)
+	}
+	fmt.Println("Archived:", archived)
+
+	if signErr != nil {
+		return processRejected, nil
+	}
+	return processSigned, nil
+}
+
+// rejectable reports whether err is a decision about the intent itself:
+// invalid, refused by the policy or the ledger, or declined by the
+// operator. Other errors are faults on the operator's side, not reasons to
+// reject the intent.
+func rejectable(err error) bool {
+	if errors.Is(err, errCanceled) {
+		return true
+	}
+	switch errorCode(err) {
+	case codeIntentInvalid, codePolicy, codeNonce:
+		return true
+	}
+	return false
+}
+
+// pendingIntents lists the regular, non-hidden files in inbox by name.
+func pendingIntents(inbox string) ([]string, error) {
+	entries, err := os.ReadDir(inbox)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// archiveFile moves inbox/name into inbox/archive, adding a numeric suffix
+// instead of replacing an earlier file with the same name.
+func archiveFile(inbox, name string) (string, error) {
+	dir := filepath.Join(inbox, archiveDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join(dir, name)
+	for i := 1; helpers.FileExists(dst); i++ {
+		dst = filepath.Join(dir, fmt.Sprintf("%s.%d", name, i))
+	}
+	if err := os.Rename(filepath.Join(inbox, name), dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// writeNewJSON writes v as indented JSON to a file that must not exist yet.
+func writeNewJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return helpers.WriteNewFile(path, append(b, '\n'), 0o600)
+}
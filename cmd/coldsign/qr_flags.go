@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"coldsign/helpers"
+	"coldsign/qr"
+)
+
+// qrFlags holds the QR output flags shared by sign and addr.
+type qrFlags struct {
+	terminal *bool
+	png      *string
+	svg      *string
+	ascii    *bool
+	level    *string
+	scale    *int
+	alnum    *bool
+}
+
+func addQRFlags(fs *flag.FlagSet, what string) *qrFlags {
+	return &qrFlags{
+		terminal: fs.Bool("qr", false, "print "+what+" as terminal QR (to stderr)"),
+		png:      fs.String("qr-png", "", "write "+what+" QR to a PNG `file`"),
+		svg:      fs.String("qr-svg", "", "write "+what+" QR to an SVG `file`"),
+		ascii:    fs.Bool("qr-ascii", false, "render terminal QR with plain ASCII (for non-Unicode consoles)"),
+		level:    fs.String("qr-level", "L", "QR error-correction `level`: L, M, Q or H"),
+		scale:    fs.Int("qr-scale", 8, "QR module size in `pixels` for PNG/SVG output"),
+		alnum:    fs.Bool("qr-alnum", false, "upper-case hex payloads to use QR alphanumeric mode (smaller code)"),
+	}
+}
+
+func (f *qrFlags) options() (qr.Options, error) {
+	opts := qr.DefaultOptions()
+
+	level, err := qr.ParseLevel(*f.level)
+	if err != nil {
+		return opts, err
+	}
+	opts.Level = level
+	opts.Scale = *f.scale
+	opts.ASCII = *f.ascii
+	opts.Alnum = *f.alnum
+	return opts, nil
+}
+
+// check validates the flags before any work is done, including that no
+// QR image file would be overwritten.
+func (f *qrFlags) check() error {
+	if _, err := f.options(); err != nil {
+		return err
+	}
+	for _, path := range []string{*f.png, *f.svg} {
+		if path != "" && helpers.FileExists(path) {
+			return fmt.Errorf("%s already exists; QR images are never overwritten", path)
+		}
+	}
+	return nil
+}
+
+// emit renders payload to every requested QR destination.
+// A nil *qrFlags (commands without QR flags) emits nothing.
+func (f *qrFlags) emit(title, payload string) error {
+	if f == nil || !*f.terminal && *f.png == "" && *f.svg == "" {
+		return nil
+	}
+
+	opts, err := f.options()
+	if err != nil {
+		return err
+	}
+
+	if *f.terminal {
+		fmt.Fprintf(os.Stderr, "\n--- %s ---\n", title)
+		if err := qr.PrintWithOptions(os.Stderr, payload, opts); err != nil {
+			return err
+		}
+	}
+	if *f.png != "" {
+		if err := qr.WritePNG(*f.png, payload, opts); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "QR written:", *f.png)
+	}
+	if *f.svg != "" {
+		if err := qr.WriteSVG(*f.svg, payload, opts); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "QR written:", *f.svg)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"coldsign/helpers"
+)
+
+// review is the human-readable summary shown before signing. It is built
+// once and then printed, or embedded in other outputs such as the signing
+// sheet.
+type review struct {
+	title  string
+	fields []reviewField
+}
+
+type reviewField struct {
+	label string
+	value string
+}
+
+func (r *review) add(label, format string, args ...any) {
+	r.fields = append(r.fields, reviewField{label: label, value: fmt.Sprintf(format, args...)})
+}
+
+func (r *review) print(w io.Writer) {
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, helpers.Separator(r.title))
+	for _, f := range r.fields {
+		fmt.Fprintf(w, "%-9s%s\n", f.label+":", f.value)
+	}
+	fmt.Fprintln(w, helpers.Separator(""))
+}
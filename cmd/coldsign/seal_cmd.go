@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"coldsign/sealed"
+)
+
+func runSeal(args []string) int {
+	fs := flag.NewFlagSet("seal", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	to := fs.String("to", "", "recipient X25519 public `key` (hex)")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *to == "" || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: coldsign seal --to KEY <file|->")
+		return 2
+	}
+
+	recipient, err := sealed.ParseRecipient(*to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "--to:", err)
+		return 2
+	}
+
+	in, err := readIntentArg(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "read error:", err)
+		return 1
+	}
+
+	out, err := sealed.Seal(recipient, []byte(strings.TrimSpace(string(in))))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "seal error:", err)
+		return 1
+	}
+	fmt.Println(out)
+	return 0
+}
+
+func runUnseal(args []string) int {
+	fs := flag.NewFlagSet("unseal", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	keyPath := fs.String("key", "", "X25519 private key `file` (default: derive from seed)")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: coldsign unseal [--key FILE] <file|->")
+		return 2
+	}
+
+	in, err := readIntentArg(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "read error:", err)
+		return 1
+	}
+
+	var seed seedInput
+	defer seed.wipe()
+
+	priv, _, err := seed.deviceKey(*keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "key error:", err)
+		return 1
+	}
+
+	plain, err := sealed.Open(priv, string(in))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unseal error:", err)
+		return 1
+	}
+	fmt.Println(string(plain))
+	return 0
+}
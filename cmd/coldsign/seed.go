@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"fmt"
+
+	"coldsign/hd"
+	"coldsign/helpers"
+	"coldsign/sealed"
+)
+
+// seedInput holds the mnemonic and passphrase once entered, so the operator
+// is prompted at most once per run even when the seed is needed both to
+// decrypt an intent and to sign it.
+type seedInput struct {
+	mnemonic   string
+	passphrase string
+	ok         bool
+}
+
+func (s *seedInput) read() error {
+	if s.ok {
+		return nil
+	}
+
+	mnemonic, err := helpers.ReadHiddenLineFromTTY(
+		"ENTER MNEMONIC (space-separated BIP-39 words; hidden)",
+		false,
+	)
+	if err != nil {
+		return fmt.Errorf("mnemonic error: %w", err)
+	}
+
+	passphrase, err := helpers.ReadHiddenLineFromTTY(
+		"ENTER PASSPHRASE (optional; hidden)",
+		true,
+	)
+	if err != nil {
+		helpers.ZeroString(&mnemonic)
+		return fmt.Errorf("passphrase error: %w", err)
+	}
+
+	s.mnemonic, s.passphrase, s.ok = mnemonic, passphrase, true
+	return nil
+}
+
+func (s *seedInput) wipe() {
+	helpers.ZeroString(&s.mnemonic)
+	helpers.ZeroString(&s.passphrase)
+	s.ok = false
+}
+
+// deviceKey returns the cold machine's transport decryption key: from
+// keyPath if given, otherwise derived from the seed.
+func (s *seedInput) deviceKey(keyPath string) (*ecdh.PrivateKey, string, error) {
+	if keyPath != "" {
+		priv, err := sealed.LoadKey(keyPath)
+		if err != nil {
+			return nil, "", err
+		}
+		return priv, "device key file", nil
+	}
+
+	if err := s.read(); err != nil {
+		return nil, "", err
+	}
+	secret, err := hd.DeriveDeviceSecret(s.mnemonic, s.passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		for i := range secret {
+			secret[i] = 0
+		}
+	}()
+
+	priv, err := sealed.KeyFromSeedMaterial(secret)
+	if err != nil {
+		return nil, "", err
+	}
+	return priv, "seed-derived device key", nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"time"
+
+	"coldsign/helpers"
+	"coldsign/qr"
+)
+
+// sheetTemplate is a self-contained, print-ready signing record. It has no
+// external resources so it renders identically on any offline machine.
+var sheetTemplate = template.Must(template.New("sheet").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>coldsign signing sheet {{.TxHash}}</title>
+<style>
+  body { font-family: monospace; font-size: 11pt; margin: 2em; color: #000; background: #fff; }
+  h1 { font-size: 14pt; border-bottom: 2px solid #000; padding-bottom: 0.3em; }
+  h2 { font-size: 12pt; margin-top: 1.5em; }
+  table { border-collapse: collapse; width: 100%; }
+  td { border: 1px solid #000; padding: 0.3em 0.5em; vertical-align: top; word-break: break-all; }
+  td.label { width: 22%; font-weight: bold; word-break: normal; }
+  .qr { margin: 1em 0; width: 60mm; height: 60mm; }
+  .qr svg { width: 100%; height: 100%; }
+  .raw { word-break: break-all; font-size: 8pt; }
+  .sig td { height: 3em; }
+  @media print { body { margin: 1cm; } }
+</style>
+</head>
+<body>
+<h1>coldsign signing sheet</h1>
+
+<h2>Transaction review</h2>
+<table>
+{{range .Review}}<tr><td class="label">{{.Label}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+
+<h2>Record</h2>
+<table>
+<tr><td class="label">Signed at (UTC)</td><td>{{.SignedAt}}</td></tr>
+{{if .IntentID}}<tr><td class="label">Intent ID</td><td>{{.IntentID}}</td></tr>
+{{end}}<tr><td class="label">Intent hash</td><td>{{.IntentHash}}</td></tr>
+<tr><td class="label">Tx hash</td><td>{{.TxHash}}</td></tr>
+<tr><td class="label">Policy hash</td><td>{{.PolicyHash}}</td></tr>
+<tr><td class="label">coldsign version</td><td>{{.Version}}</td></tr>
+</table>
+
+<h2>{{.OutputTitle}}</h2>
+<div class="qr">{{.QR}}</div>
+<p class="raw">{{.Output}}</p>
+
+<h2>Signatures</h2>
+<table class="sig">
+<tr><td class="label">Operator name</td><td></td></tr>
+<tr><td class="label">Operator signature</td><td></td></tr>
+<tr><td class="label">Witness name</td><td></td></tr>
+<tr><td class="label">Witness signature</td><td></td></tr>
+<tr><td class="label">Date</td><td></td></tr>
+</table>
+</body>
+</html>
+`))
+
+type sheetData struct {
+	Review      []struct{ Label, Value string }
+	SignedAt    string
+	IntentID    string
+	IntentHash  string
+	TxHash      string
+	PolicyHash  string
+	Version     string
+	OutputTitle string
+	Output      string
+	QR          template.HTML
+}
+
+// writeSheet writes a printable record of a signing to path. The file must
+// not already exist.
+func writeSheet(path string, out *signOutcome, opts *signOptions) error {
+	qrOpts := qr.DefaultOptions()
+	if opts.qrOut != nil {
+		o, err := opts.qrOut.options()
+		if err != nil {
+			return err
+		}
+		qrOpts = o
+	}
+	qrOpts.Scale = 4
+
+	svg, err := qr.SVG(out.output, qrOpts)
+	if err != nil {
+		return err
+	}
+
+	data := sheetData{
+		SignedAt:    time.Now().UTC().Format(time.RFC3339),
+		IntentID:    out.intent.IntentID,
+		IntentHash:  out.intentHash,
+		TxHash:      out.signed.TxHash,
+		PolicyHash:  opts.pol.Hash(),
+		Version:     Version,
+		OutputTitle: "Signed raw transaction",
+		Output:      out.output,
+		// svg is generated by the qr package from a fixed set of elements
+		QR: template.HTML(svg),
+	}
+	if opts.outputRecipient != nil {
+		data.OutputTitle = "Encrypted signed transaction"
+	}
+	for _, f := range out.review.fields {
+		data.Review = append(data.Review, struct{ Label, Value string }{f.label, f.value})
+	}
+
+	var b bytes.Buffer
+	if err := sheetTemplate.Execute(&b, data); err != nil {
+		return err
+	}
+	return helpers.WriteNewFile(path, b.Bytes(), 0o600)
+}
@@ -0,0 +1,1096 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"coldsign/addrbook"
+	"coldsign/hd"
+	"coldsign/helpers"
+	"coldsign/intent"
+	"coldsign/ledger"
+	"coldsign/policy"
+	"coldsign/sealed"
+	"coldsign/signer"
+	"coldsign/tx"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// errNotAuthorized means the intent was reviewed but --sign was not given.
+	errNotAuthorized = errors.New("NOT SIGNED: pass --sign to authorize signing")
+	// errCanceled means the operator declined at the confirmation prompt.
+	errCanceled = errors.New("Canceled.")
+	// errNoTTY means confirmation was required but no terminal is available.
+	errNoTTY = errors.New("no TTY available; re-run with --yes")
+)
+
+// signOptions is the per-run configuration shared by every intent reviewed
+// in one invocation.
+type signOptions struct {
+	sign            bool
+	yes             bool
+	pol             *policy.Policy
+	coordinators    []intent.Coordinator
+	book            *addrbook.Book // nil if none is configured
+	led             *ledger.Ledger
+	decryptKey      string
+	outputRecipient *ecdh.PublicKey
+	qrOut           *qrFlags
+	partial         []byte    // fields merged into payment URIs; nil if none given
+	human           io.Writer // review and progress text: stdout, or stderr with --output json
+	jsonOut         bool      // write a signDocument per intent to stdout
+}
+
+// signOutcome is the result of signing one intent.
+type signOutcome struct {
+	intent      *intent.EthSendIntent
+	intentHash  string // SHA-256 of the canonical intent
+	canonical   []byte // canonical intent as received, before toLabel resolution
+	dest        *addrbook.Entry
+	eval        *policy.Evaluation // every policy check, for the review and JSON output
+	review      *review
+	warnings    []string // policy warnings the operator was shown
+	policyOK    bool     // every policy check passed
+	unsignedTx  *types.Transaction
+	signingHash string
+	signed      *signer.Result
+	output      string // raw tx hex, or the sealed payload when encrypting
+}
+
+// Stable failure codes for --output json.
+const (
+	codeDecrypt       = "decrypt_failed"
+	codeIntentInvalid = "intent_invalid"
+	codePolicy        = "policy_violation"
+	codeNotAuthorized = "not_authorized"
+	codeCanceled      = "canceled"
+	codeNoTTY         = "no_tty"
+	codeSeed          = "seed_input_failed"
+	codeDerive        = "derive_failed"
+	codeFromMismatch  = "from_address_mismatch"
+	codeBuild         = "build_failed"
+	codeSign          = "sign_failed"
+	codeLedger        = "ledger_failed"
+	codeNonce         = "nonce_conflict"
+	codeOutput        = "output_failed"
+	codeInternal      = "internal_error"
+)
+
+// signError tags a signIntent failure with one of the codes above.
+type signError struct {
+	code string
+	err  error
+}
+
+func (e *signError) Error() string { return e.err.Error() }
+func (e *signError) Unwrap() error { return e.err }
+
+func failf(code, format string, args ...any) error {
+	return &signError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// errorCode returns the stable code for an error from signIntent.
+func errorCode(err error) string {
+	var se *signError
+	switch {
+	case errors.Is(err, errNotAuthorized):
+		return codeNotAuthorized
+	case errors.Is(err, errCanceled):
+		return codeCanceled
+	case errors.Is(err, errNoTTY):
+		return codeNoTTY
+	case errors.As(err, &se):
+		return se.code
+	default:
+		return codeInternal
+	}
+}
+
+// reviewFlags are the flags that decide how an intent is read and judged,
+// shared by sign, process and policy check.
+type reviewFlags struct {
+	coordinatorsPath *string
+	addressBookPath  *string
+	policyPath       *string
+	adminsPath       *string
+	allowUnsigned    *bool
+	ledgerPath       *string
+	intentAuth       *string
+	clock            *string
+	decryptKey       *string
+
+	// Payment URI completion (see intent.PaymentRequest.Complete)
+	partialPath *string
+	chainID     *string
+	nonce       *string
+	fromIndex   *string
+	fromAddress *string
+	maxFee      *string
+	maxPrioFee  *string
+	gasLimit    *string
+}
+
+// signFlags are the review flags plus those that authorize signing and
+// shape its output, shared by sign and process.
+type signFlags struct {
+	*reviewFlags
+	sign      *bool
+	yes       *bool
+	encryptTo *string
+}
+
+func addSignFlags(fs *flag.FlagSet) *signFlags {
+	return &signFlags{
+		reviewFlags: addReviewFlags(fs),
+		sign:        fs.Bool("sign", false, "authorize signing (otherwise only review)"),
+		yes:         fs.Bool("yes", false, "skip interactive confirmation (DANGEROUS)"),
+		encryptTo:   fs.String("encrypt-output-to", "", "encrypt the signed tx to this X25519 public `key` (hex)"),
+	}
+}
+
+func addReviewFlags(fs *flag.FlagSet) *reviewFlags {
+	return &reviewFlags{
+		coordinatorsPath: fs.String("coordinators", "", "trusted coordinator keys `file` (default "+helpers.ConfigPath("coordinators.json")+")"),
+		addressBookPath:  fs.String("address-book", "", "address book `file` (default "+helpers.ConfigPath("addressbook.json")+")"),
+		policyPath:       fs.String("policy", "", "policy `file` (default "+helpers.ConfigPath("policy.json")+", else built-in defaults)"),
+		adminsPath:       fs.String("admins", "", "policy administrator keys `file` (default "+helpers.ConfigPath("admins.json")+")"),
+		allowUnsigned:    fs.Bool("allow-unsigned-policy", false, "accept a policy file without administrator signatures, until a signed one has been accepted"),
+		ledgerPath:       fs.String("ledger", "", "signing ledger `file` (default "+helpers.StatePath("ledger.jsonl")+")"),
+		intentAuth:       fs.String("intent-auth", "", "unauthenticated intents: refuse or warn (overrides policy)"),
+		clock:            fs.String("clock", "", "local clock for intent expiry: trusted or untrusted (overrides policy)"),
+		decryptKey:       fs.String("decrypt-key", "", "device key `file` for encrypted intents (default: derive from seed)"),
+
+		partialPath: fs.String("partial", "", "partial intent `file` completing an ethereum: payment URI"),
+		chainID:     fs.String("chain-id", "", "payment URI completion: chainId"),
+		nonce:       fs.String("nonce", "", "payment URI completion: nonce"),
+		fromIndex:   fs.String("from-index", "", "payment URI completion: BIP-44 from.index"),
+		fromAddress: fs.String("from-address", "", "payment URI completion: fromAddress"),
+		maxFee:      fs.String("max-fee-per-gas-wei", "", "payment URI completion: maxFeePerGasWei"),
+		maxPrioFee:  fs.String("max-priority-fee-per-gas-wei", "", "payment URI completion: maxPriorityFeePerGasWei"),
+		gasLimit:    fs.String("gas-limit", "", "payment URI completion: gasLimit"),
+	}
+}
+
+// partialIntent merges --partial with the individual completion flags
+// (flags win). It returns nil when none were given.
+func (f *reviewFlags) partialIntent() ([]byte, error) {
+	m := map[string]any{}
+	given := false
+
+	if *f.partialPath != "" {
+		b, err := os.ReadFile(*f.partialPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := intent.CheckStrictJSON(b); err != nil {
+			return nil, fmt.Errorf("%s: %w", *f.partialPath, err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("%s: %w", *f.partialPath, err)
+		}
+		given = true
+	}
+
+	for _, n := range []struct {
+		key string
+		val string
+	}{
+		{"chainId", *f.chainID},
+		{"nonce", *f.nonce},
+		{"from.index", *f.fromIndex},
+		{"gasLimit", *f.gasLimit},
+	} {
+		if n.val == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(n.val, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid %s: %q", n.key, n.val)
+		}
+		if n.key == "from.index" {
+			m["from"] = map[string]any{"type": "bip44_index", "index": json.Number(n.val)}
+		} else {
+			m[n.key] = json.Number(n.val)
+		}
+		given = true
+	}
+
+	for _, s := range []struct {
+		key string
+		val string
+	}{
+		{"fromAddress", *f.fromAddress},
+		{"maxFeePerGasWei", *f.maxFee},
+		{"maxPriorityFeePerGasWei", *f.maxPrioFee},
+	} {
+		if s.val == "" {
+			continue
+		}
+		m[s.key] = s.val
+		given = true
+	}
+
+	if !given {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+// options builds the run configuration for signing. On failure it has
+// already reported the problem and returns the exit code to use.
+func (f *signFlags) options(qrOut *qrFlags) (*signOptions, int) {
+	opts, code := f.reviewFlags.options(true)
+	if opts == nil {
+		return nil, code
+	}
+	opts.sign = *f.sign
+	opts.yes = *f.yes
+	opts.qrOut = qrOut
+
+	if *f.encryptTo != "" {
+		recipient, err := sealed.ParseRecipient(*f.encryptTo)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "--encrypt-output-to:", err)
+			return nil, 2
+		}
+		opts.outputRecipient = recipient
+	}
+	return opts, 0
+}
+
+// options builds the run configuration for reviewing intents without
+// signing them. record says whether a newly accepted signed policy is
+// recorded (see loadPolicy); a command that signs nothing leaves the
+// record alone.
+func (f *reviewFlags) options(record bool) (*signOptions, int) {
+	pol, err := loadPolicy(*f.policyPath, *f.adminsPath, *f.allowUnsigned, record)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "policy error:", err)
+		return nil, 1
+	}
+	opts := &signOptions{
+		pol:        pol,
+		decryptKey: *f.decryptKey,
+		human:      os.Stdout,
+	}
+
+	if err := overridePolicy(pol, *f.intentAuth, *f.clock); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, 2
+	}
+
+	partial, err := f.partialIntent()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "partial intent error:", err)
+		return nil, 2
+	}
+	opts.partial = partial
+
+	coordinators, err := loadCoordinators(*f.coordinatorsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "coordinators error:", err)
+		return nil, 1
+	}
+	opts.coordinators = coordinators
+
+	book, err := loadAddressBook(*f.addressBookPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "address book error:", err)
+		return nil, 1
+	}
+	opts.book = book
+
+	led, err := openLedger(*f.ledgerPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ledger error:", err)
+		return nil, 1
+	}
+	opts.led = led
+
+	return opts, 0
+}
+
+func runSign(args []string) int {
+	fs := flag.NewFlagSet("sign", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	qrOut := addQRFlags(fs, "signed raw tx")
+	intentStdin := fs.Bool("intent-stdin", false, "read intent from stdin (JSON or coldintent:v1:<base64url>)")
+	intentStream := fs.Bool("intent-stream", false, "read intents from stdin until EOF, one per line, skipping duplicates")
+	sheetPath := fs.String("sheet", "", "write a printable signing sheet (HTML) to `file` after signing")
+	output := addOutputFlag(fs)
+	sf := addSignFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	asJSON, err := jsonOutput(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := qrOut.check(); err != nil {
+		fmt.Fprintln(os.Stderr, "qr error:", err)
+		return 2
+	}
+
+	if *sheetPath != "" && helpers.FileExists(*sheetPath) {
+		fmt.Fprintln(os.Stderr, "sheet error: file already exists:", *sheetPath)
+		return 2
+	}
+
+	opts, code := sf.options(qrOut)
+	if opts == nil {
+		return code
+	}
+	if asJSON {
+		opts.human = os.Stderr
+		opts.jsonOut = true
+	}
+
+	// inputFailed reports an input error before any intent was decoded.
+	inputFailed := func(err error) int {
+		fmt.Fprintln(os.Stderr, err)
+		if asJSON {
+			writeJSONDocument(newSignDocument(nil, &signError{codeInput, err}, 1, opts))
+		}
+		return 1
+	}
+
+	var seed seedInput
+	defer seed.wipe()
+
+	if *intentStream {
+		if *intentStdin || fs.NArg() != 0 {
+			fmt.Fprintln(os.Stderr, "usage: coldsign sign --intent-stream [flags]")
+			return 2
+		}
+		if *qrOut.png != "" || *qrOut.svg != "" || *sheetPath != "" {
+			fmt.Fprintln(os.Stderr, "--qr-png/--qr-svg/--sheet cannot be used with --intent-stream")
+			return 2
+		}
+		return runSignStream(os.Stdin, opts, &seed)
+	}
+
+	var rawInput []byte
+
+	if *intentStdin {
+		fmt.Fprintln(os.Stderr, "READY: waiting for intent on stdin (JSON or coldintent:v1:...)")
+		fmt.Fprintln(os.Stderr, "Tip: zbarcam --raw | coldsign sign --intent-stdin ...")
+
+		reader := bufio.NewReader(os.Stdin)
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return inputFailed(fmt.Errorf("stdin read error: %w", readErr))
+		}
+		rawInput = []byte(strings.TrimSpace(line))
+		if len(rawInput) == 0 {
+			return inputFailed(errors.New("stdin error: no intent provided"))
+		}
+	} else {
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "usage: coldsign sign [flags] <intent.json>")
+			return 2
+		}
+		rawInput, err = os.ReadFile(fs.Arg(0))
+		if err != nil {
+			return inputFailed(fmt.Errorf("read error: %w", err))
+		}
+	}
+
+	out, err := signIntent(rawInput, opts, &seed)
+	if err == nil && *sheetPath != "" {
+		if sheetErr := writeSheet(*sheetPath, out, opts); sheetErr != nil {
+			err = failf(codeOutput, "sheet error: %w", sheetErr)
+		} else {
+			fmt.Fprintln(os.Stderr, "Signing sheet written:", *sheetPath)
+		}
+	}
+
+	code = signExitCode(opts.human, err)
+	if asJSON {
+		writeJSONDocument(newSignDocument(out, err, code, opts))
+	}
+	return code
+}
+
+// signExitCode reports err from signIntent and maps it to an exit code.
+// The review-only notice goes to w, with the review.
+func signExitCode(w io.Writer, err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errNotAuthorized):
+		fmt.Fprintln(w, err)
+		return 2
+	case errors.Is(err, errCanceled):
+		fmt.Fprintln(os.Stderr, err)
+		return 0
+	case errors.Is(err, errNoTTY):
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+}
+
+// signIntent reviews one raw intent (see reviewIntent) and, when
+// authorized, signs it. Once the intent has been parsed the outcome is
+// returned even on failure, so callers can report which intent was
+// refused.
+func signIntent(rawInput []byte, opts *signOptions, seed *seedInput) (*signOutcome, error) {
+	d, err := decodeIntent(rawInput, opts, seed)
+	if err != nil {
+		return nil, err
+	}
+	return signDecoded(d, opts, seed)
+}
+
+// signDecoded is signIntent for an intent already decoded.
+func signDecoded(d *decodedIntent, opts *signOptions, seed *seedInput) (*signOutcome, error) {
+	out, err := reviewDecoded(d, opts, "SIGNING REVIEW")
+	if err != nil {
+		return out, err
+	}
+	in := out.intent
+
+	if !opts.sign {
+		return out, errNotAuthorized
+	}
+
+	if !opts.yes {
+		if err := confirmDestination(in.To, out.dest); err != nil {
+			return out, err
+		}
+	}
+
+	if err := seed.read(); err != nil {
+		return out, &signError{codeSeed, err}
+	}
+
+	privKey, addr, err := hd.DeriveEthKey(seed.mnemonic, seed.passphrase, in.From.Index)
+	if err != nil {
+		return out, failf(codeDerive, "hd derive error: %w", err)
+	}
+
+	if addr.Hex() != common.HexToAddress(in.FromAddress).Hex() {
+		return out, failf(codeFromMismatch, "fromAddress mismatch")
+	} else {
+		fmt.Fprintln(opts.human, "From address verified:", addr.Hex())
+	}
+
+	signed, err := signer.SignEIP1559Tx(out.unsignedTx, in.ChainID, privKey)
+	if err != nil {
+		return out, failf(codeSign, "sign error: %w", err)
+	}
+
+	// Record the transaction before releasing it: one that cannot be
+	// counted against the limits is not handed out.
+	if err := opts.led.Append(ledger.NewRecord(in, out.intentHash, out.signingHash, signed.TxHash, time.Now())); err != nil {
+		return out, failf(codeLedger, "ledger error: %w (the signed transaction was not released)", err)
+	}
+
+	fmt.Fprintln(opts.human, helpers.Separator(""))
+	fmt.Fprintln(opts.human, "Signed tx hash:", signed.TxHash)
+
+	if in.IntentID != "" {
+		fmt.Fprintln(opts.human, "Intent ID:", in.IntentID)
+	}
+
+	out.signed = signed
+	out.output = signed.RawTxHex
+	qrTitle := "SIGNED RAW TX QR"
+	if opts.outputRecipient != nil {
+		out.output, err = sealed.Seal(opts.outputRecipient, []byte(signed.RawTxHex))
+		if err != nil {
+			return out, failf(codeOutput, "encrypt error: %w", err)
+		}
+		qrTitle = "ENCRYPTED SIGNED TX QR"
+		fmt.Fprintln(opts.human, "Encrypted signed tx:", out.output)
+	} else {
+		fmt.Fprintln(opts.human, "Signed raw tx hex:", signed.RawTxHex)
+	}
+
+	if err := opts.qrOut.emit(qrTitle, out.output); err != nil {
+		return out, failf(codeOutput, "qr error: %w", err)
+	}
+
+	fmt.Fprintln(opts.human, "DONE: signed transaction ready for broadcast")
+	return out, nil
+}
+
+// decodedIntent is a raw intent opened, decoded and parsed, before any
+// review.
+type decodedIntent struct {
+	intent      *intent.EthSendIntent
+	hash        string
+	canonical   []byte
+	auth        intent.AuthResult
+	payloadNote string
+	requestURI  string // the payment URI the intent was completed from
+}
+
+// decodeIntent opens an encrypted intent with the device key, which may
+// need the seed, then decodes, authenticates and parses it. The error is a
+// signError.
+func decodeIntent(rawInput []byte, opts *signOptions, seed *seedInput) (*decodedIntent, error) {
+	payloadNote := "NOT encrypted"
+	if sealed.IsSealed(string(rawInput)) {
+		devKey, source, err := seed.deviceKey(opts.decryptKey)
+		if err != nil {
+			return nil, failf(codeDecrypt, "decrypt key error: %w", err)
+		}
+		rawInput, err = sealed.Open(devKey, string(rawInput))
+		if err != nil {
+			return nil, failf(codeDecrypt, "intent decrypt error: %w", err)
+		}
+		payloadNote = "encrypted (" + source + ")"
+	}
+
+	env, err := intent.DecodeEnvelope(string(rawInput))
+	if err != nil {
+		return nil, failf(codeIntentInvalid, "intent decode error: %w", err)
+	}
+	auth := env.Authenticate(opts.coordinators)
+
+	var intentJSON []byte
+	if env.PaymentRequest != nil {
+		intentJSON, err = env.PaymentRequest.Complete(opts.partial)
+		if err != nil {
+			return nil, failf(codeIntentInvalid, "payment URI error: %w", err)
+		}
+	} else {
+		if opts.partial != nil {
+			return nil, failf(codeIntentInvalid, "intent error: --partial and completion flags only apply to ethereum: payment URIs")
+		}
+		intentJSON, err = env.JSON()
+		if err != nil {
+			return nil, failf(codeIntentInvalid, "intent decode error: %w", err)
+		}
+	}
+	in, err := intent.Parse(intentJSON)
+	if err != nil {
+		return nil, failf(codeIntentInvalid, "intent error: %w", err)
+	}
+	intentHash, err := in.Hash()
+	if err != nil {
+		return nil, failf(codeInternal, "intent hash error: %w", err)
+	}
+	canonical, err := in.Canonical()
+	if err != nil {
+		return nil, failf(codeInternal, "intent encode error: %w", err)
+	}
+	d := &decodedIntent{
+		intent:      in,
+		hash:        intentHash,
+		canonical:   canonical,
+		auth:        auth,
+		payloadNote: payloadNote,
+	}
+	if env.PaymentRequest != nil {
+		d.requestURI = env.PaymentRequest.URI
+	}
+	return d, nil
+}
+
+// reviewIntent decodes one raw intent, checks it against the ledger and
+// the policy, and prints the review under title to opts.human; prompts go
+// to stderr. An encrypted intent is opened with the device key, which may
+// need the seed. The error is a signError, with codePolicy if the policy
+// refuses the intent. sign and policy check both judge intents here, so
+// they cannot disagree.
+func reviewIntent(rawInput []byte, opts *signOptions, seed *seedInput, title string) (*signOutcome, error) {
+	d, err := decodeIntent(rawInput, opts, seed)
+	if err != nil {
+		return nil, err
+	}
+	return reviewDecoded(d, opts, title)
+}
+
+// reviewDecoded is reviewIntent for an intent already decoded.
+func reviewDecoded(d *decodedIntent, opts *signOptions, title string) (*signOutcome, error) {
+	in, intentHash, auth := d.intent, d.hash, d.auth
+	out := &signOutcome{intent: in, intentHash: intentHash, canonical: d.canonical}
+
+	// A label is resolved from the local address book only; the intent
+	// hash covers the intent as the sender wrote it.
+	var err error
+	out.dest, err = resolveDestination(in, opts.book)
+	if err != nil {
+		return out, failf(codeIntentInvalid, "intent error: %w", err)
+	}
+
+	// Validate addresses before proceeding
+	if !common.IsHexAddress(in.To) {
+		return out, failf(codeIntentInvalid, "intent error: invalid to address")
+	}
+	if !common.IsHexAddress(in.FromAddress) {
+		return out, failf(codeIntentInvalid, "intent error: invalid fromAddress")
+	}
+
+	unsignedTx, err := tx.BuildUnsignedTx(in)
+	if err != nil {
+		return out, failf(codeBuild, "tx build error: %w", err)
+	}
+	signingHash := signer.SigningHash(unsignedTx, in.ChainID)
+	nonces, nonceErr := opts.led.CheckNonce(in, signingHash)
+	now := time.Now()
+	out.eval = opts.pol.Evaluate(in, policy.Facts{Dest: out.dest, Auth: auth, Ledger: opts.led, Now: now})
+
+	out.unsignedTx, out.signingHash = unsignedTx, signingHash
+
+	rv := &review{title: title + " (" + in.Kind + ")"}
+	if in.IntentID != "" {
+		rv.add("ID", "%s", in.IntentID)
+	}
+	if c := opts.pol.ForAccount(in.From.Index).Chain(in.ChainID); c != nil {
+		rv.add("Chain", "%s", helpers.SafeText(c.Label(in.ChainID)))
+	} else {
+		rv.add("Chain", "%d (not allowed by policy)", in.ChainID)
+	}
+	rv.add("From", "%s", in.FromAddress)
+	addRole(rv, opts.pol, in.From.Index)
+	rv.add("To", "%s%s", in.To, destinationNote(in, out.dest, opts.book))
+	rv.add("Nonce", "%d%s", in.Nonce, nonceNote(nonces, signingHash))
+	if nonces.Replaces != nil {
+		what := "replaced"
+		if in.Kind == intent.KindEthSend && in.ValueWei == "0" && strings.EqualFold(in.To, in.FromAddress) {
+			what = "canceled"
+		}
+		rv.add("Old tx", "%s  (%s by this tx; signed %s)", nonces.Replaces.TxHash, what, nonces.Replaces.Time)
+	}
+
+	if in.Kind == intent.KindERC20Transfer {
+		// Token decimals are not known offline, so show raw base units.
+		rv.add("Token", "%s", in.Token)
+		rv.add("Amount", "%s token base units", in.TokenAmount)
+		rv.add("Gas", "%d", in.Gas())
+	} else {
+		amtEth, err := helpers.FormatETH(in.ValueWei)
+		if err != nil {
+			return out, failf(codeIntentInvalid, "invalid valueWei: %w", err)
+		}
+		rv.add("Amount", "%s ETH  (%s wei)%s", amtEth, in.ValueWei, writtenAs(in.Value))
+	}
+
+	maxGwei, err := helpers.FormatGwei(in.MaxFeePerGasWei)
+	if err != nil {
+		return out, failf(codeIntentInvalid, "invalid maxFeePerGasWei: %w", err)
+	}
+	tipGwei, err := helpers.FormatGwei(in.MaxPriorityFeePerGasWei)
+	if err != nil {
+		return out, failf(codeIntentInvalid, "invalid maxPriorityFeePerGasWei: %w", err)
+	}
+	rv.add("Fees", "max=%s gwei, tip=%s gwei", maxGwei, tipGwei)
+	if in.BaseFeeWei != "" {
+		baseGwei, err := helpers.FormatGwei(in.BaseFeeWei)
+		if err != nil {
+			return out, failf(codeIntentInvalid, "invalid baseFeeWei: %w", err)
+		}
+		if auth.Authenticated() {
+			rv.add("Basefee", "%s gwei  (snapshot signed by the coordinator)", baseGwei)
+		} else {
+			rv.add("Basefee", "%s gwei  (UNSIGNED snapshot; not relied on)", baseGwei)
+		}
+	}
+	if in.MaxFee != "" || in.MaxPriorityFee != "" {
+		rv.add("Fee wei", "max=%s wei%s, tip=%s wei%s",
+			in.MaxFeePerGasWei, writtenAs(in.MaxFee), in.MaxPriorityFeePerGasWei, writtenAs(in.MaxPriorityFee))
+	}
+
+	// Worst-case: gas * maxFeePerGasWei
+	mfWei, ok := new(big.Int).SetString(in.MaxFeePerGasWei, 10)
+	if !ok {
+		return out, failf(codeIntentInvalid, "invalid maxFeePerGasWei: %s", in.MaxFeePerGasWei)
+	}
+
+	worstWei := new(big.Int).Mul(new(big.Int).SetUint64(in.Gas()), mfWei)
+	worstEth, err := helpers.FormatETH6(worstWei.String())
+	if err != nil {
+		return out, failf(codeInternal, "fee cap format error: %w", err)
+	}
+
+	rv.add("Fee cap", "~%s ETH worst-case", worstEth)
+	rv.add("Intent", "%s  (compare with the sender)", intent.ShortHash(intentHash))
+	rv.add("Payload", "%s", d.payloadNote)
+	rv.add("Origin", "%s", auth)
+	rv.add("Policy", "%s", policyNote(opts.pol))
+	if d.requestURI != "" {
+		rv.add("Request", "%s", d.requestURI)
+	}
+	addChecks(rv, out.eval)
+	addMetadata(rv, in, now)
+
+	rv.print(opts.human)
+	out.review = rv
+
+	if nonceErr != nil {
+		return out, failf(codeNonce, "nonce conflict: %w", nonceErr)
+	}
+	if w := nonces.Warning(); w != "" {
+		printWarning(w)
+		out.warnings = append(out.warnings, w)
+	}
+
+	for _, w := range out.eval.Warnings() {
+		printWarning(w)
+		out.warnings = append(out.warnings, w)
+	}
+	if err := out.eval.Err(); err != nil {
+		return out, failf(codePolicy, "policy violation: %w", err)
+	}
+
+	out.policyOK = true
+	fmt.Fprintln(opts.human, "Policy check: OK")
+	return out, nil
+}
+
+// addMetadata adds the optional intent metadata to the review. Free text is
+// escaped so it cannot drive the terminal.
+func addMetadata(rv *review, in *intent.EthSendIntent, now time.Time) {
+	if in.CreatedAt != "" {
+		rv.add("Created", "%s", in.CreatedAt)
+	}
+	if until := in.ExpiryTime(); !until.IsZero() {
+		if left := until.Sub(now); left > 0 {
+			rv.add("Expires", "%s  (in %s, by local clock)", in.ValidUntil, roughDuration(left))
+		} else {
+			rv.add("Expires", "%s  (EXPIRED %s ago, by local clock)", in.ValidUntil, roughDuration(-left))
+		}
+	}
+	if in.RequestedBy != "" {
+		rv.add("Req. by", "%s", helpers.SafeText(in.RequestedBy))
+	}
+	if in.Memo != "" {
+		rv.add("Memo", "%s", helpers.SafeText(in.Memo))
+	}
+}
+
+// nonceNote describes the nonce against the ledger for the review.
+func nonceNote(c *ledger.NonceCheck, signingHash string) string {
+	switch {
+	case c.Replaces != nil:
+		return "  (REPLACEMENT)"
+	case len(c.Previous) > 0 && c.Resign(signingHash):
+		return "  (already signed: same transaction)"
+	case len(c.Previous) > 0:
+		return "  (ALREADY SIGNED for a different transaction)"
+	case c.Warning() != "":
+		return fmt.Sprintf("  (GAP: highest signed is %d)", c.Highest)
+	case c.Seen:
+		return fmt.Sprintf("  (highest signed is %d)", c.Highest)
+	default:
+		return "  (first for this sender in the ledger)"
+	}
+}
+
+// writtenAs notes the unit-aware form an amount was written in, if any.
+func writtenAs(written string) string {
+	if written == "" {
+		return ""
+	}
+	return fmt.Sprintf(" [written %q]", written)
+}
+
+// roughDuration formats d for a human: days and hours, or hours and
+// minutes under two days.
+func roughDuration(d time.Duration) string {
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%dd%dh", d/(24*time.Hour), d%(24*time.Hour)/time.Hour)
+	}
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", d/time.Hour, d%time.Hour/time.Minute)
+}
+
+// confirmDestination asks the operator to re-type a fragment of the
+// destination address on the terminal. A destination that is not in the
+// address book needs a longer fragment.
+func confirmDestination(toAddr string, dest *addrbook.Entry) error {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return errNoTTY
+	}
+	defer tty.Close()
+
+	to := common.HexToAddress(toAddr).Hex()
+	n := 4
+	if dest == nil {
+		n = 8
+	}
+	// Generate confirmation code once and reuse for display and validation
+	first := strings.ToLower(to[2 : 2+n])
+	last := strings.ToLower(to[len(to)-n:])
+	code := fmt.Sprintf("%s %s", first, last)
+
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, helpers.Separator("CONFIRM SIGNING"))
+	fmt.Fprintln(os.Stderr, "Destination address:")
+	fmt.Fprintln(os.Stderr, to)
+	if dest != nil {
+		fmt.Fprintln(os.Stderr, "Address book:", dest)
+	} else {
+		fmt.Fprintln(os.Stderr, "Address book: NOT LISTED (check the full address)")
+	}
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Re-type the destination address fragment exactly as shown:")
+	fmt.Fprintln(os.Stderr, code)
+	fmt.Fprint(os.Stderr, "> ")
+
+	resp, _ := bufio.NewReader(tty).ReadString('\n')
+	got := strings.Fields(strings.ToLower(resp))
+	want := strings.Fields(code) // ["1111","1111"]
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		return errCanceled
+	}
+	return nil
+}
+
+// destinationNote describes the recipient's address book entry for the
+// review.
+func destinationNote(in *intent.EthSendIntent, dest *addrbook.Entry, book *addrbook.Book) string {
+	switch {
+	case dest != nil && in.ToLabel != "":
+		return fmt.Sprintf("  %s, resolved locally from toLabel", dest)
+	case dest != nil:
+		return fmt.Sprintf("  %s", dest)
+	case book != nil:
+		return "  (not in address book)"
+	default:
+		return ""
+	}
+}
+
+// addRole shows which account policy applies to the sender, when the
+// policy has any.
+func addRole(rv *review, pol *policy.Policy, index uint32) {
+	if len(pol.Accounts) == 0 {
+		return
+	}
+	if a := pol.Accounts[index]; a != nil {
+		rv.add("Role", "%s  (account policy for index %d)", a.Role, index)
+	} else {
+		rv.add("Role", "none  (index %d has no account policy; policy defaults apply)", index)
+	}
+}
+
+// resolveDestination finds the recipient in the address book. A toLabel is
+// resolved into in.To; if the intent also carries to, the two must agree.
+func resolveDestination(in *intent.EthSendIntent, book *addrbook.Book) (*addrbook.Entry, error) {
+	if in.ToLabel == "" {
+		return book.Lookup(in.ChainID, common.HexToAddress(in.To)), nil
+	}
+	dest, err := book.Resolve(in.ChainID, in.ToLabel)
+	if err != nil {
+		return nil, err
+	}
+	if in.To != "" && common.HexToAddress(in.To) != dest.Address {
+		return nil, fmt.Errorf("to %s does not match address book entry %q (%s)", in.To, dest.Label, dest.Address.Hex())
+	}
+	in.To = dest.Address.Hex()
+	return dest, nil
+}
+
+// loadAddressBook reads the address book. With no explicit path, the
+// default config file is used if it exists.
+func loadAddressBook(path string) (*addrbook.Book, error) {
+	if path == "" {
+		path = helpers.ConfigPath("addressbook.json")
+		if path == "" || !helpers.FileExists(path) {
+			return nil, nil
+		}
+	}
+	return addrbook.Load(path)
+}
+
+// openLedger opens the signing ledger, by default in the state directory.
+// The file is created when the first transaction is signed.
+func openLedger(path string) (*ledger.Ledger, error) {
+	if path == "" {
+		path = helpers.StatePath("ledger.jsonl")
+		if path == "" {
+			return nil, errors.New("no home directory for the default ledger; pass --ledger")
+		}
+	}
+	return ledger.Open(path)
+}
+
+// loadCoordinators reads the trusted coordinator keys. With no explicit
+// path, the default config file is used if it exists.
+func loadCoordinators(path string) ([]intent.Coordinator, error) {
+	if path == "" {
+		path = helpers.ConfigPath("coordinators.json")
+		if path == "" || !helpers.FileExists(path) {
+			return nil, nil
+		}
+	}
+	return intent.LoadCoordinators(path)
+}
+
+// loadPolicy reads the signing policy. With no explicit path, the default
+// config file is used if it exists; only when it does not are the built-in
+// defaults used. A policy file that exists but is invalid is an error.
+//
+// A policy file must carry enough administrator signatures (see
+// verifyPolicy); allowUnsigned accepts one with no signature file at all.
+// Any policy, signed or not, is refused if it is older than the newest
+// signed policy accepted before. That record needs a home directory,
+// except for the built-in defaults: without one no record can exist.
+// With record, a signed policy newer than the recorded one becomes the
+// new record.
+func loadPolicy(path, adminsPath string, allowUnsigned, record bool) (*policy.Policy, error) {
+	if path == "" {
+		path = helpers.ConfigPath("policy.json")
+		if path == "" || !helpers.FileExists(path) {
+			path = ""
+		}
+	}
+
+	pol := policy.Default()
+	if path != "" {
+		var err error
+		if pol, err = policy.Load(path); err != nil {
+			return nil, err
+		}
+		if err := verifyPolicy(pol, path, adminsPath, allowUnsigned); err != nil {
+			return nil, err
+		}
+		if pol.Signers == nil {
+			printWarning("policy " + pol.Source + " is NOT SIGNED; accepted because of --allow-unsigned-policy")
+		}
+	}
+
+	statePath := helpers.StatePath("policy-accepted.json")
+	if statePath == "" {
+		if pol.Source == policy.DefaultSource {
+			return pol, nil
+		}
+		return nil, errors.New("no home directory for the policy version record")
+	}
+	if err := pol.CheckDowngrade(statePath); err != nil {
+		return nil, err
+	}
+	if record {
+		if err := pol.RecordAccepted(statePath, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return pol, nil
+}
+
+// overridePolicy applies the --intent-auth and --clock overrides; empty
+// values leave the policy as it is. A signed policy may only be made
+// stricter: refuse over warn, an untrusted clock over a trusted one.
+func overridePolicy(pol *policy.Policy, intentAuth, clock string) error {
+	switch intentAuth {
+	case "", pol.UnauthenticatedIntents:
+	case policy.IntentAuthRefuse:
+		pol.UnauthenticatedIntents = intentAuth
+		pol.Overrides = append(pol.Overrides, "--intent-auth "+intentAuth)
+	case policy.IntentAuthWarn:
+		if pol.Signers != nil {
+			return errors.New("--intent-auth warn: the signed policy refuses unauthenticated intents; an override may only make it stricter")
+		}
+		pol.UnauthenticatedIntents = intentAuth
+		pol.Overrides = append(pol.Overrides, "--intent-auth "+intentAuth)
+	default:
+		return errors.New("invalid --intent-auth: want refuse or warn")
+	}
+
+	switch clock {
+	case "", pol.Clock:
+	case policy.ClockTrusted:
+		pol.Clock = clock
+		pol.Overrides = append(pol.Overrides, "--clock "+clock)
+	case policy.ClockUntrusted:
+		// Untrusted turns expired intents into warnings.
+		if pol.Signers != nil {
+			return errors.New("--clock untrusted: the signed policy refuses expired intents by the local clock; an override may only make it stricter")
+		}
+		pol.Clock = clock
+		pol.Overrides = append(pol.Overrides, "--clock "+clock)
+	default:
+		return errors.New("invalid --clock: want trusted or untrusted")
+	}
+	return nil
+}
+
+// verifyPolicy checks the signature file next to the policy file against
+// the administrator keys.
+func verifyPolicy(pol *policy.Policy, path, adminsPath string, allowUnsigned bool) error {
+	sigPath := policy.SignaturePath(path)
+	if !helpers.FileExists(sigPath) {
+		if allowUnsigned {
+			return nil
+		}
+		return fmt.Errorf("%s is not signed (no %s); sign it with coldsign policy sign, or pass --allow-unsigned-policy", path, sigPath)
+	}
+	sigs, err := policy.LoadSignatures(sigPath)
+	if err != nil {
+		return err
+	}
+
+	if adminsPath == "" {
+		adminsPath = helpers.ConfigPath("admins.json")
+		if adminsPath == "" || !helpers.FileExists(adminsPath) {
+			return fmt.Errorf("%s is signed, but no administrator keys are configured (%s)", path, adminsPath)
+		}
+	}
+	admins, err := policy.LoadAdmins(adminsPath)
+	if err != nil {
+		return err
+	}
+	if err := pol.Verify(sigs, admins); err != nil {
+		return fmt.Errorf("%s: %w", sigPath, err)
+	}
+	return nil
+}
+
+// addChecks adds a row per policy check to the review: the result, what
+// the intent has and what the policy allows.
+func addChecks(rv *review, ev *policy.Evaluation) {
+	for _, c := range ev.Checks {
+		rv.add("Check", "%-4s %s: %s; policy: %s", strings.ToUpper(c.Result), c.Name, c.Actual, c.Limit)
+	}
+}
+
+// policyNote describes the policy in force for the review.
+func policyNote(pol *policy.Policy) string {
+	hash := intent.ShortHash(pol.Hash())
+	var note string
+	switch {
+	case pol.Signers == nil:
+		note = fmt.Sprintf("%s (%s), version %d, UNSIGNED", pol.Source, hash, pol.Version)
+	default:
+		note = fmt.Sprintf("%s (%s), version %d, signed by %s (%d required)",
+			pol.Source, hash, pol.Version, strings.Join(pol.Signers, ", "), pol.Threshold)
+	}
+	if len(pol.Overrides) > 0 {
+		note += "; OVERRIDDEN by " + strings.Join(pol.Overrides, ", ")
+	}
+	return note
+}
+
+// printWarning prints a hard-to-miss warning banner to stderr.
+func printWarning(msg string) {
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, strings.Repeat("!", 51))
+	fmt.Fprintln(os.Stderr, "WARNING:", msg)
+	fmt.Fprintln(os.Stderr, strings.Repeat("!", 51))
+	fmt.Fprintln(os.Stderr, "")
+}
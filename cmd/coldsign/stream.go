@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"coldsign/helpers"
+)
+
+// maxStreamLine bounds a single scanned intent (large CBOR/JSON envelopes
+// still fit comfortably).
+const maxStreamLine = 1 << 20
+
+// streamItem is one non-blank line read from the stream.
+type streamItem struct {
+	raw  []byte
+	line int
+}
+
+// runSignStream reads intents from r until EOF, one per line (NDJSON or
+// repeated scanner output), and reviews each distinct one in arrival order.
+// Each line is decoded first; lines whose intent hash has already been seen
+// are dropped, so a scanner that emits the same intent many times, in any
+// encoding, yields a single review. Lines that do not decode are refused
+// with their line number.
+//
+// An intent is only seen once it is signed, reviewed without --sign or
+// refused for itself (see rejectable); one the operator canceled can be
+// scanned again. Any other failure, such as a wrong seed, wipes the seed
+// and stops the stream, so a bad seed is not reused for later intents.
+func runSignStream(r io.Reader, opts *signOptions, seed *seedInput) int {
+	fmt.Fprintln(os.Stderr, "READY: streaming intents from stdin (JSON or coldintent:v1:...), one per line")
+	fmt.Fprintln(os.Stderr, "Tip: zbarcam --raw | coldsign sign --intent-stream ...")
+
+	queue := make(chan streamItem, 64)
+	readErr := make(chan error, 1)
+	go readStream(r, queue, readErr)
+
+	seen := make(map[string]bool)
+	var n, signed, failed, rejected int
+	stopped := false
+	for item := range queue {
+		d, err := decodeIntent(item.raw, opts, seed)
+		if err != nil {
+			rejected++
+			err = fmt.Errorf("line %d: %w", item.line, err)
+			code := signExitCode(opts.human, err)
+			if opts.jsonOut {
+				writeJSONDocument(newSignDocument(nil, err, code, opts))
+			}
+			if !rejectable(err) {
+				stopped = true
+				break
+			}
+			continue
+		}
+		if seen[d.hash] {
+			continue
+		}
+
+		n++
+		fmt.Fprintln(opts.human, "")
+		fmt.Fprintln(opts.human, helpers.Separator(fmt.Sprintf("INTENT #%d (%s, %d queued)", n, d.hash[:12], len(queue))))
+
+		out, err := signDecoded(d, opts, seed)
+		code := signExitCode(opts.human, err)
+		if opts.jsonOut {
+			writeJSONDocument(newSignDocument(out, err, code, opts))
+		}
+		switch {
+		case err == nil:
+			signed++
+			seen[d.hash] = true
+		case errors.Is(err, errNotAuthorized):
+			seen[d.hash] = true
+		case errors.Is(err, errCanceled):
+		case rejectable(err):
+			failed++
+			seen[d.hash] = true
+		default:
+			failed++
+			stopped = true
+		}
+		if stopped {
+			break
+		}
+	}
+
+	if stopped {
+		// The seed may be what failed; never reuse it.
+		seed.wipe()
+		fmt.Fprintln(os.Stderr, "Stopped: the rest of the stream was not read")
+	} else if err := <-readErr; err != nil {
+		fmt.Fprintln(os.Stderr, "stdin read error:", err)
+		failed++
+	}
+
+	fmt.Fprintln(opts.human, "")
+	fmt.Fprintln(opts.human, helpers.Separator("STREAM SUMMARY"))
+	fmt.Fprintf(opts.human, "Intents: %d distinct, %d signed, %d refused or failed; %d lines not decoded\n", n, signed, failed, rejected)
+
+	if n == 0 && rejected == 0 {
+		fmt.Fprintln(os.Stderr, "stdin error: no intent provided")
+		return 1
+	}
+	if failed > 0 || rejected > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readStream scans lines from r, drops blanks, and queues the rest with
+// their line numbers. It closes queue at EOF and reports any read error on
+// errc.
+func readStream(r io.Reader, queue chan<- streamItem, errc chan<- error) {
+	defer close(queue)
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxStreamLine)
+
+	for n := 1; sc.Scan(); n++ {
+		line := strings.Trim(strings.TrimSpace(sc.Text()), "\"")
+		if line == "" {
+			continue
+		}
+		queue <- streamItem{raw: []byte(line), line: n}
+	}
+	errc <- sc.Err()
+}
@@ -64,3 +64,38 @@ func DeriveEthKey(mnemonic, passphrase string, index uint32) (*ecdsa.PrivateKey,
 	addr := crypto.PubkeyToAddress(priv.PublicKey)
 	return priv, addr, nil
 }
+
+// deviceKeyPurpose is the hardened purpose used for the cold machine's
+// transport encryption key. It is outside BIP-44 so the key is unrelated
+// to any spending account.
+const deviceKeyPurpose = 7476
+
+// DeriveDeviceSecret derives the private key bytes at m/7476'/0'/0' from a
+// BIP-39 mnemonic. The caller turns them into a device encryption key and
+// should wipe the returned slice when done.
+func DeriveDeviceSecret(mnemonic, passphrase string) ([]byte, error) {
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, fmt.Errorf("invalid mnemonic")
+	}
+
+	seed := bip39.NewSeed(mnemonic, passphrase)
+
+	key, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, fmt.Errorf("new master: %w", err)
+	}
+
+	// Path: m/7476'/0'/0'
+	for _, i := range []uint32{deviceKeyPurpose, 0, 0} {
+		key, err = key.Derive(hdkeychain.HardenedKeyStart + i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ecPriv, err := key.ECPrivKey()
+	if err != nil {
+		return nil, fmt.Errorf("ec priv: %w", err)
+	}
+	return ecPriv.Serialize(), nil
+}
@@ -0,0 +1,21 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ConfigPath returns the path of a coldsign configuration file:
+// $XDG_CONFIG_HOME/coldsign/<name>, or ~/.config/coldsign/<name>.
+// It returns "" when no home directory can be determined.
+func ConfigPath(name string) string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "coldsign", name)
+}
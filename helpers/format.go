@@ -14,6 +14,34 @@ const (
 	Eth
 )
 
+// exp returns the power of ten of one unit in wei.
+func (u Unit) exp() (int, bool) {
+	switch u {
+	case Wei:
+		return 0, true
+	case Gwei:
+		return 9, true
+	case Eth:
+		return 18, true
+	default:
+		return 0, false
+	}
+}
+
+// ParseUnit parses a unit name: wei, gwei, eth or ether (any case).
+func ParseUnit(s string) (Unit, error) {
+	switch strings.ToLower(s) {
+	case "wei":
+		return Wei, nil
+	case "gwei":
+		return Gwei, nil
+	case "eth", "ether":
+		return Eth, nil
+	default:
+		return 0, fmt.Errorf("unknown unit %q (want wei, gwei or ETH)", s)
+	}
+}
+
 // FormatWeiString converts a base-10 wei string into the requested unit,
 // returning a decimal string with `decimals` digits after the decimal point.
 // For Unit=Wei, decimals is ignored and the original integer is returned normalized.
@@ -36,16 +64,75 @@ func FormatWeiString(weiStr string, unit Unit, decimals int) (string, error) {
 		return "", fmt.Errorf("decimals too large")
 	}
 
-	switch unit {
-	case Wei:
-		return wei.String(), nil
-	case Gwei:
-		return formatByExp(wei, 9, decimals), nil
-	case Eth:
-		return formatByExp(wei, 18, decimals), nil
-	default:
+	exp, ok := unit.exp()
+	if !ok {
 		return "", fmt.Errorf("unknown unit")
 	}
+	if exp == 0 {
+		return wei.String(), nil
+	}
+	return formatByExp(wei, exp, decimals), nil
+}
+
+// ParseWeiString is the reverse of FormatWeiString: it converts an amount
+// with a unit into a base-10 wei string. Accepted forms:
+//
+//	"0.01 ETH", "35 gwei", "35gwei", "1000 wei"   decimal amount and unit
+//	"0x2386f26fc10000"                            hex quantity in wei
+//
+// Conversion is exact. Amounts finer than one wei, signs, exponents and
+// leading zeros are rejected rather than rounded.
+func ParseWeiString(s string) (string, error) {
+	if strings.HasPrefix(s, "0x") {
+		return parseHexQuantity(s[2:])
+	}
+
+	// Split "<number>[ ]<unit>" at the first letter.
+	i := strings.IndexFunc(s, func(r rune) bool { return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' })
+	if i < 0 {
+		return "", fmt.Errorf("amount %q has no unit (want e.g. \"0.01 ETH\" or \"35 gwei\")", s)
+	}
+	num := strings.TrimSuffix(s[:i], " ")
+	unit, err := ParseUnit(s[i:])
+	if err != nil {
+		return "", err
+	}
+	exp, _ := unit.exp()
+
+	whole, frac, hasFrac := strings.Cut(num, ".")
+	if !isPlainDecimal(whole) || hasFrac && (frac == "" || strings.Trim(frac, "0123456789") != "") {
+		return "", fmt.Errorf("invalid amount %q", s)
+	}
+	if len(strings.TrimRight(frac, "0")) > exp {
+		return "", fmt.Errorf("amount %q is more precise than 1 wei", s)
+	}
+
+	// whole * 10^exp + frac padded to exp digits
+	frac = strings.TrimRight(frac, "0")
+	digits := whole + frac + strings.Repeat("0", exp-len(frac))
+	wei, _ := new(big.Int).SetString(digits, 10)
+	return wei.String(), nil
+}
+
+// parseHexQuantity parses the digits of an Ethereum JSON-RPC quantity:
+// at least one digit and no leading zeros.
+func parseHexQuantity(h string) (string, error) {
+	if h == "" || len(h) > 1 && h[0] == '0' {
+		return "", fmt.Errorf("invalid hex quantity %q (no leading zeros)", "0x"+h)
+	}
+	wei, ok := new(big.Int).SetString(h, 16)
+	if !ok {
+		return "", fmt.Errorf("invalid hex quantity %q", "0x"+h)
+	}
+	return wei.String(), nil
+}
+
+// isPlainDecimal reports whether s is digits only, without leading zeros.
+func isPlainDecimal(s string) bool {
+	if s == "" || len(s) > 1 && s[0] == '0' {
+		return false
+	}
+	return strings.Trim(s, "0123456789") == ""
 }
 
 // formatByExp formats integer `n` as n / 10^exp with `decimals` fractional digits.
@@ -0,0 +1,33 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SafeText makes untrusted text safe to print on a terminal. Control
+// characters (including ESC), invisible format characters such as
+// bidirectional overrides and zero-width spaces, and invalid UTF-8 are
+// shown as escapes, so a memo cannot move the cursor, recolor the screen or
+// reorder what the reviewer reads. Backslashes are doubled to keep the
+// escaping unambiguous.
+func SafeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\x%02x`, s[i])
+		case r == '\\':
+			b.WriteString(`\\`)
+		case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	return b.String()
+}
@@ -0,0 +1,21 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// StatePath returns the path of a file coldsign writes and keeps between
+// runs: $XDG_STATE_HOME/coldsign/<name>, or ~/.local/state/coldsign/<name>.
+// It returns "" when no home directory can be determined.
+func StatePath(name string) string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "coldsign", name)
+}
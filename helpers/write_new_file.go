@@ -0,0 +1,22 @@
+package helpers
+
+import "os"
+
+// WriteNewFile writes data to path, failing if the file already exists.
+// The data is synced to disk before returning, which matters on removable
+// media that may be unplugged right after.
+func WriteNewFile(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,52 @@
+package intent
+
+import "coldsign/helpers"
+
+// Amounts may be written with units instead of wei: "value": "0.01 ETH",
+// "maxFee": "35 gwei", "maxPriorityFee": "0x59682f00". Parse converts them
+// exactly into the matching *Wei field (see helpers.ParseWeiString) before
+// validation and policy. The written form is kept for the review but is not
+// part of the canonical intent, so "0.01 ETH" and "10000000000000000" wei
+// hash the same.
+
+// amountField pairs a unit-aware field with the wei field it fills.
+type amountField struct {
+	path, weiPath string
+	written       *string
+	wei           *string
+}
+
+func (in *EthSendIntent) amountFields() []amountField {
+	return []amountField{
+		{"/value", "/valueWei", &in.Value, &in.ValueWei},
+		{"/maxFee", "/maxFeePerGasWei", &in.MaxFee, &in.MaxFeePerGasWei},
+		{"/maxPriorityFee", "/maxPriorityFeePerGasWei", &in.MaxPriorityFee, &in.MaxPriorityFeePerGasWei},
+	}
+}
+
+// normalizeAmounts fills the wei fields from their unit-aware forms.
+func (in *EthSendIntent) normalizeAmounts(ps *problems) {
+	for _, f := range in.amountFields() {
+		if *f.written == "" {
+			continue
+		}
+		if *f.wei != "" {
+			ps.add(f.path, "give %s or %s, not both", f.path[1:], f.weiPath[1:])
+			continue
+		}
+		wei, err := helpers.ParseWeiString(*f.written)
+		if err != nil {
+			ps.add(f.path, "%v", err)
+			continue
+		}
+		*f.wei = wei
+	}
+}
+
+// withoutWrittenAmounts returns a copy of the intent without the
+// unit-aware fields, leaving only their normalized wei values.
+func (in *EthSendIntent) withoutWrittenAmounts() *EthSendIntent {
+	c := *in
+	c.Value, c.MaxFee, c.MaxPriorityFee = "", "", ""
+	return &c
+}
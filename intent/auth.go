@@ -0,0 +1,126 @@
+package intent
+
+import (
+	"fmt"
+	"os"
+
+	"coldsign/keys"
+)
+
+// Coordinator is an online-side key trusted to originate intents.
+type Coordinator struct {
+	Name string
+	Key  keys.PublicKey
+}
+
+// coordinatorsFile is the on-disk list of trusted coordinator keys:
+//
+//	{"v":1,"coordinators":[{"name":"ops","alg":"ed25519","publicKey":"<hex>"}]}
+type coordinatorsFile struct {
+	V            int `json:"v"`
+	Coordinators []struct {
+		Name      string `json:"name"`
+		Alg       string `json:"alg"`
+		PublicKey string `json:"publicKey"`
+	} `json:"coordinators"`
+}
+
+// LoadCoordinators reads the trusted coordinator keys from path. Names and
+// keys must be unique, and at least one key must be listed.
+func LoadCoordinators(path string) ([]Coordinator, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f coordinatorsFile
+	if err := DecodeStrict(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.V != 1 {
+		return nil, fmt.Errorf("%s: /v: unsupported coordinators file version %d (want 1)", path, f.V)
+	}
+
+	out := make([]Coordinator, 0, len(f.Coordinators))
+	for i, c := range f.Coordinators {
+		if !ValidLabel(c.Name) {
+			return nil, fmt.Errorf("%s: /coordinators/%d/name: must be 1 to 64 letters, digits or . _ : -", path, i)
+		}
+		key, err := keys.ParsePublicKey(c.Alg, c.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: /coordinators/%d: %w", path, i, err)
+		}
+		for _, other := range out {
+			if other.Name == c.Name {
+				return nil, fmt.Errorf("%s: /coordinators/%d/name: %q is listed twice", path, i, c.Name)
+			}
+			if other.Key.Equal(key) {
+				return nil, fmt.Errorf("%s: /coordinators/%d/publicKey: same key as %q", path, i, other.Name)
+			}
+		}
+		out = append(out, Coordinator{Name: c.Name, Key: key})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s: /coordinators: must list at least one key", path)
+	}
+	return out, nil
+}
+
+// AuthStatus describes how an intent's origin was established.
+type AuthStatus int
+
+const (
+	AuthUnsigned   AuthStatus = iota // no coordinator signature
+	AuthTrusted                      // valid signature from a trusted coordinator
+	AuthUnknownKey                   // valid signature from a key that is not trusted
+	AuthInvalid                      // signature does not verify
+)
+
+// AuthResult is the outcome of authenticating an envelope.
+type AuthResult struct {
+	Status      AuthStatus
+	Coordinator string         // set when Status == AuthTrusted
+	Key         keys.PublicKey // signing key, when signed
+}
+
+// Authenticated reports whether the intent was signed by a trusted coordinator.
+func (r AuthResult) Authenticated() bool { return r.Status == AuthTrusted }
+
+func (r AuthResult) String() string {
+	switch r.Status {
+	case AuthTrusted:
+		return fmt.Sprintf("signed by trusted coordinator %q (%s %s)", r.Coordinator, r.Key.Alg, r.Key.Fingerprint())
+	case AuthUnknownKey:
+		return fmt.Sprintf("signed by UNKNOWN key (%s %s)", r.Key.Alg, r.Key.Fingerprint())
+	case AuthInvalid:
+		return fmt.Sprintf("INVALID signature (%s %s)", r.Key.Alg, r.Key.Fingerprint())
+	default:
+		return "UNSIGNED"
+	}
+}
+
+// Authenticate checks the envelope signature against the trusted
+// coordinators. A signature is only valid over a payload in canonical form
+// (see SignIntent).
+func (e *Envelope) Authenticate(trusted []Coordinator) AuthResult {
+	if e.Signature == nil {
+		return AuthResult{Status: AuthUnsigned}
+	}
+
+	res := AuthResult{Key: e.Signature.Key}
+	canonical, err := e.signedIntent()
+	if err != nil || !e.Signature.Key.Verify(signedMessage(canonical), e.Signature.Sig) {
+		res.Status = AuthInvalid
+		return res
+	}
+
+	for _, c := range trusted {
+		if c.Key.Equal(e.Signature.Key) {
+			res.Status = AuthTrusted
+			res.Coordinator = c.Name
+			return res
+		}
+	}
+	res.Status = AuthUnknownKey
+	return res
+}
@@ -0,0 +1,286 @@
+package intent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Intents are parsed strictly. Plain encoding/json accepts input that reads
+// differently to a human than to the signer: with "to" given twice it keeps
+// the last one, "To" silently fills the to field, unknown fields are
+// dropped and anything after the object is ignored. decodeStrict refuses
+// all of these, so what the reviewer sees is all there is.
+
+// decodeStrict decodes a single JSON object into v (a pointer to a struct),
+// rejecting:
+//   - duplicate keys
+//   - keys that are not exactly a field name (including case variants)
+//   - null values
+//   - numbers that are not plain non-negative integers (no sign, fraction,
+//     exponent or leading zero)
+//   - anything but whitespace after the object
+//   - invalid UTF-8 and \u escapes of unpaired surrogates, which
+//     encoding/json would silently turn into U+FFFD
+func decodeStrict(b []byte, v any) error {
+	if err := checkStrictJSON(b, reflect.TypeOf(v).Elem()); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return decodeProblem(dec.Decode(v))
+}
+
+// DecodeStrict decodes a JSON object into v (a pointer to a struct) under
+// the same rules as intents, for other documents the signer trusts, such as
+// policy files.
+func DecodeStrict(b []byte, v any) error {
+	return decodeStrict(b, v)
+}
+
+// CheckStrictJSON applies the syntactic decodeStrict rules to a JSON object
+// without checking field names: no duplicate keys, nulls, non-canonical
+// numbers, invalid UTF-8 or trailing data.
+func CheckStrictJSON(b []byte) error {
+	return checkStrictJSON(b, nil)
+}
+
+// checkStrictJSON applies the decodeStrict rules to b. With a nil t only
+// the syntactic rules apply and any field name is allowed.
+func checkStrictJSON(b []byte, t reflect.Type) error {
+	if err := checkUTF8(b); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return invalid("", "must be a JSON object")
+	}
+	if err := checkObject(dec, t, ""); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return invalid("", "unexpected data after the JSON object")
+	}
+	return nil
+}
+
+// checkUTF8 refuses invalid UTF-8 and \u escapes of unpaired surrogates in
+// b. Either would decode to U+FFFD, so different inputs would read as the
+// same intent. Malformed escapes are left to the JSON decoder.
+func checkUTF8(b []byte) error {
+	if !utf8.Valid(b) {
+		return invalid("", "invalid UTF-8 at byte %d", len(validPrefix(b)))
+	}
+	inString := false
+	for i := 0; i < len(b); i++ {
+		switch {
+		case b[i] == '"':
+			inString = !inString
+		case inString && b[i] == '\\' && i+1 < len(b):
+			i++
+			if b[i] != 'u' {
+				continue
+			}
+			r, ok := escapedRune(b[i+1:])
+			if !ok {
+				continue
+			}
+			i += 4
+			switch {
+			case utf16.IsSurrogate(r) && r < 0xdc00:
+				if i+2 < len(b) && b[i+1] == '\\' && b[i+2] == 'u' {
+					if lo, ok := escapedRune(b[i+3:]); ok && lo >= 0xdc00 && lo <= 0xdfff {
+						i += 6
+						continue
+					}
+				}
+				return invalid("", "unpaired surrogate \\u%04x at byte %d", r, i-5)
+			case utf16.IsSurrogate(r):
+				return invalid("", "unpaired surrogate \\u%04x at byte %d", r, i-5)
+			}
+		}
+	}
+	return nil
+}
+
+// escapedRune parses the four hex digits of a \u escape at the start of b.
+func escapedRune(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(string(b[:4]), 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	return rune(n), true
+}
+
+// validPrefix returns the longest prefix of b that is valid UTF-8.
+func validPrefix(b []byte) []byte {
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return b[:i]
+		}
+		i += size
+	}
+	return b
+}
+
+// checkObject checks the members of an object whose '{' has been read.
+func checkObject(dec *json.Decoder, t reflect.Type, path string) error {
+	fields := jsonFields(t)
+	seen := make(map[string]bool)
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+		p := path + pointer(key)
+
+		if seen[key] {
+			return invalid(p, "duplicate field")
+		}
+		seen[key] = true
+
+		var ft reflect.Type
+		switch {
+		case fields != nil:
+			var ok bool
+			if ft, ok = fields[key]; !ok {
+				return invalid(p, "unknown field")
+			}
+		case t != nil && t.Kind() == reflect.Map:
+			ft = t.Elem()
+		}
+		if err := checkValue(dec, ft, p); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token() // '}'
+	return err
+}
+
+func checkValue(dec *json.Decoder, t reflect.Type, path string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch x := tok.(type) {
+	case nil:
+		return invalid(path, "null is not allowed")
+	case json.Number:
+		if !isCanonicalDecimal(x.String()) {
+			return invalid(path, "non-canonical number %s (want a plain non-negative integer)", x)
+		}
+	case json.Delim:
+		switch x {
+		case '{':
+			return checkObject(dec, t, path)
+		case '[':
+			var elem reflect.Type
+			if t != nil && t.Kind() == reflect.Slice {
+				elem = t.Elem()
+			}
+			for i := 0; dec.More(); i++ {
+				if err := checkValue(dec, elem, fmt.Sprintf("%s/%d", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err := dec.Token() // ']'
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonFields maps the JSON field names of struct type t to their types. It
+// returns nil for a nil or non-struct t.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// Canonical returns the canonical JSON serialization of the intent: keys
+// sorted, no insignificant whitespace, empty optional fields omitted, amounts
+// in wei only and no HTML escaping. The same intent has the same canonical
+// bytes however it was written or carried (JSON, CBOR or a completed
+// payment URI).
+func (in *EthSendIntent) Canonical() ([]byte, error) {
+	b, err := json.Marshal(in.withoutWrittenAmounts())
+	if err != nil {
+		return nil, err
+	}
+
+	// Round-trip through a map so encoding/json sorts the keys.
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Hash returns the hex SHA-256 of the canonical serialization.
+func (in *EthSendIntent) Hash() (string, error) {
+	b, err := in.Canonical()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ShortHash formats the first 64 bits of an intent hash in groups of four,
+// short enough to read aloud and compare between machines.
+func ShortHash(h string) string {
+	if len(h) > 16 {
+		h = h[:16]
+	}
+	var groups []string
+	for len(h) > 4 {
+		groups = append(groups, h[:4])
+		h = h[4:]
+	}
+	return strings.Join(append(groups, h), " ")
+}
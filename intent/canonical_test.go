@@ -0,0 +1,112 @@
+package intent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStrict(t *testing.T) {
+	tests := []struct {
+		name, old, new, wantErr string
+	}{
+		{"duplicate key", `"nonce":7`, `"nonce":7,"nonce":8`, "duplicate field"},
+		{"duplicate nested key", `"index":0`, `"index":0,"index":1`, "duplicate field"},
+		{"unknown field", `"nonce":7`, `"nonce":7,"gasPrice":"1"`, "unknown field"},
+		{"case variant", `"to":`, `"To":`, "unknown field"},
+		{"null", `"nonce":7`, `"nonce":null`, "null is not allowed"},
+		{"negative number", `"nonce":7`, `"nonce":-1`, "non-canonical number"},
+		{"fraction", `"nonce":7`, `"nonce":7.0`, "non-canonical number"},
+		{"exponent", `"nonce":7`, `"nonce":7e0`, "non-canonical number"},
+		{"leading zero", `"nonce":7`, `"nonce":07`, "invalid character"},
+		{"trailing object", `"valueWei":"500000000000000000"}`, `"valueWei":"500000000000000000"} {}`, "unexpected data after the JSON object"},
+		{"trailing garbage", `"valueWei":"500000000000000000"}`, `"valueWei":"500000000000000000"}x`, "unexpected data after the JSON object"},
+		{"invalid UTF-8", `"kind"`, "\"kind\xff\"", "invalid UTF-8 at byte"},
+		{"lone high surrogate", `"v":1`, `"v":1,"memo":"\ud83d"`, "unpaired surrogate"},
+		{"lone low surrogate", `"v":1`, `"v":1,"memo":"\ude00x"`, "unpaired surrogate"},
+		{"reversed surrogates", `"v":1`, `"v":1,"memo":"\ude00\ud83d"`, "unpaired surrogate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := strings.Replace(testIntent, tt.old, tt.new, 1)
+			if src == testIntent {
+				t.Fatalf("test case does not change the intent")
+			}
+			_, err := Parse([]byte(src))
+			if err == nil {
+				t.Fatalf("Parse(%s) succeeded, want an error", src)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckStrictJSON(t *testing.T) {
+	tests := []struct {
+		src, wantErr string
+	}{
+		{`{"a":1,"b":{"a":1}}`, ""},
+		{`{"a":"😀"}`, ""},
+		{` {"a":[1,2,"x"]} ` + "\n", ""},
+		{`{"a":1,"a":2}`, "duplicate field"},
+		{`{"a":[{"b":1,"b":1}]}`, "duplicate field"},
+		{`{"a":null}`, "null is not allowed"},
+		{`{"a":[null]}`, "null is not allowed"},
+		{`{"a":-0}`, "non-canonical number"},
+		{`{"a":1.5}`, "non-canonical number"},
+		{`{"a":1E3}`, "non-canonical number"},
+		{`[{"a":1}]`, "must be a JSON object"},
+		{`"a"`, "must be a JSON object"},
+		{`{"a":1}{"a":1}`, "unexpected data after the JSON object"},
+		{`{"a":1}]`, "unexpected data after the JSON object"},
+		{"{\"a\":\"\xc3\x28\"}", "invalid UTF-8 at byte 6"},
+		{`{"a":"\udead"}`, "unpaired surrogate"},
+		{`{"a":"\\udead"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			err := CheckStrictJSON([]byte(tt.src))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("CheckStrictJSON: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("CheckStrictJSON error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The canonical form and hash depend on the intent, not on how its JSON was
+// laid out.
+func TestCanonicalIgnoresLayout(t *testing.T) {
+	a, err := Parse([]byte(testIntent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Parse([]byte(`{
+		"v": 1, "kind": "ETH_SEND", "chainId": 1, "nonce": 7,
+		"to": "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"from": {"type": "bip44_index", "index": 0},
+		"fromAddress": "0x9858EfFD232B4033E47d90003D41EC34EcaEda94",
+		"valueWei": "500000000000000000",
+		"maxPriorityFeePerGasWei": "1500000000",
+		"maxFeePerGasWei": "30000000000"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ha, err := a.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb, err := b.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ha != hb {
+		ca, _ := a.Canonical()
+		cb, _ := b.Canonical()
+		t.Fatalf("hashes differ:\n%s\n%s", ca, cb)
+	}
+}
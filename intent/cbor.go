@@ -0,0 +1,443 @@
+package intent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Intents may be carried as deterministic CBOR (RFC 8949 §4.2.1) instead of
+// JSON to shrink QR codes. The CBOR form mirrors the JSON object field by
+// field, with two compactions:
+//   - base-10 wei strings are stored as unsigned integers (tag 2 bignums
+//     above 2^64-1)
+//   - EIP-55 checksummed addresses are stored as 20-byte byte strings
+//
+// Encoding is canonical: shortest-form heads, definite lengths, map keys
+// sorted by their encoded bytes, text only as valid UTF-8 and never where the
+// encoder would compact it. The decoder rejects anything else, so every
+// intent has exactly one CBOR form.
+
+// cborDecimalFields are JSON string fields holding base-10 integers.
+var cborDecimalFields = map[string]bool{
+	"valueWei":                true,
+	"maxFeePerGasWei":         true,
+	"maxPriorityFeePerGasWei": true,
+	"baseFeeWei":              true,
+	"tokenAmount":             true,
+}
+
+// cborAddressFields are JSON string fields holding 20-byte addresses.
+var cborAddressFields = map[string]bool{
+	"to":          true,
+	"fromAddress": true,
+	"token":       true,
+}
+
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+
+	cborTagBignum = 2
+	cborMaxDepth  = 16
+)
+
+// isCBORMap reports whether b starts with a definite-length CBOR map, which
+// never collides with JSON ('{' is a CBOR text-string head).
+func isCBORMap(b []byte) bool {
+	return len(b) > 0 && b[0] >= 0xa0 && b[0] <= 0xbb
+}
+
+// JSONToCBOR converts an intent JSON object into its deterministic CBOR form.
+// The JSON must pass CheckStrictJSON.
+func JSONToCBOR(b []byte) ([]byte, error) {
+	if err := CheckStrictJSON(b); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, ok := v.(map[string]any); !ok {
+		return nil, fmt.Errorf("intent must be a JSON object")
+	}
+
+	var out bytes.Buffer
+	if err := cborEncodeValue(&out, "", v, 0); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func cborEncodeValue(w *bytes.Buffer, key string, v any, depth int) error {
+	if depth > cborMaxDepth {
+		return fmt.Errorf("cbor: nesting too deep")
+	}
+
+	switch x := v.(type) {
+	case nil:
+		w.WriteByte(0xf6)
+	case bool:
+		if x {
+			w.WriteByte(0xf5)
+		} else {
+			w.WriteByte(0xf4)
+		}
+	case json.Number:
+		n, ok := new(big.Int).SetString(x.String(), 10)
+		if !ok {
+			return fmt.Errorf("cbor: %s: only integers are supported, got %s", key, x)
+		}
+		cborEncodeInt(w, n)
+	case string:
+		if cborDecimalFields[key] && isCanonicalDecimal(x) {
+			n, _ := new(big.Int).SetString(x, 10)
+			cborEncodeInt(w, n)
+			return nil
+		}
+		// Only checksummed addresses are compacted, so the JSON form
+		// round-trips byte for byte.
+		if cborAddressFields[key] && isHexAddress0x(x) && common.HexToAddress(x).Hex() == x {
+			addr := common.HexToAddress(x)
+			cborWriteHead(w, cborBytes, uint64(len(addr)))
+			w.Write(addr.Bytes())
+			return nil
+		}
+		cborWriteHead(w, cborText, uint64(len(x)))
+		w.WriteString(x)
+	case []any:
+		cborWriteHead(w, cborArray, uint64(len(x)))
+		for _, e := range x {
+			if err := cborEncodeValue(w, "", e, depth+1); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		type entry struct {
+			key []byte
+			val []byte
+		}
+		entries := make([]entry, 0, len(x))
+		for k, e := range x {
+			var kb, vb bytes.Buffer
+			cborWriteHead(&kb, cborText, uint64(len(k)))
+			kb.WriteString(k)
+			if err := cborEncodeValue(&vb, k, e, depth+1); err != nil {
+				return err
+			}
+			entries = append(entries, entry{kb.Bytes(), vb.Bytes()})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].key, entries[j].key) < 0
+		})
+		cborWriteHead(w, cborMap, uint64(len(entries)))
+		for _, e := range entries {
+			w.Write(e.key)
+			w.Write(e.val)
+		}
+	default:
+		return fmt.Errorf("cbor: unsupported value %T", v)
+	}
+	return nil
+}
+
+func cborEncodeInt(w *bytes.Buffer, n *big.Int) {
+	major := byte(cborUint)
+	if n.Sign() < 0 {
+		// CBOR negative integers store -1-n
+		major = cborNegInt
+		n = new(big.Int).Sub(new(big.Int).Neg(n), big.NewInt(1))
+	}
+	if n.IsUint64() {
+		cborWriteHead(w, major, n.Uint64())
+		return
+	}
+	// Bignums only appear for wei amounts, which are never negative.
+	cborWriteHead(w, cborTag, cborTagBignum)
+	b := n.Bytes()
+	cborWriteHead(w, cborBytes, uint64(len(b)))
+	w.Write(b)
+}
+
+func cborWriteHead(w *bytes.Buffer, major byte, n uint64) {
+	m := major << 5
+	switch {
+	case n < 24:
+		w.WriteByte(m | byte(n))
+	case n <= 0xff:
+		w.WriteByte(m | 24)
+		w.WriteByte(byte(n))
+	case n <= 0xffff:
+		w.WriteByte(m | 25)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	case n <= 0xffffffff:
+		w.WriteByte(m | 26)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	default:
+		w.WriteByte(m | 27)
+		binary.Write(w, binary.BigEndian, n)
+	}
+}
+
+// CBORToJSON converts a deterministic CBOR intent back into JSON. Object
+// keys keep their CBOR order.
+func CBORToJSON(b []byte) ([]byte, error) {
+	if !isCBORMap(b) {
+		return nil, fmt.Errorf("cbor: intent must be a map")
+	}
+	d := &cborDecoder{b: b}
+
+	var out bytes.Buffer
+	if err := d.value(&out, "", 0); err != nil {
+		return nil, err
+	}
+	if d.off != len(d.b) {
+		return nil, fmt.Errorf("cbor: trailing data after intent")
+	}
+	return out.Bytes(), nil
+}
+
+type cborDecoder struct {
+	b   []byte
+	off int
+}
+
+func (d *cborDecoder) head() (major byte, n uint64, err error) {
+	if d.off >= len(d.b) {
+		return 0, 0, fmt.Errorf("cbor: unexpected end of data")
+	}
+	ib := d.b[d.off]
+	d.off++
+	major, ai := ib>>5, ib&0x1f
+
+	if major == cborSimple {
+		if ai >= 24 {
+			return 0, 0, fmt.Errorf("cbor: floats and extended simple values are not supported")
+		}
+		return major, uint64(ai), nil
+	}
+
+	var size int
+	switch {
+	case ai < 24:
+		return major, uint64(ai), nil
+	case ai == 24:
+		size = 1
+	case ai == 25:
+		size = 2
+	case ai == 26:
+		size = 4
+	case ai == 27:
+		size = 8
+	default:
+		return 0, 0, fmt.Errorf("cbor: indefinite lengths are not allowed")
+	}
+	if d.off+size > len(d.b) {
+		return 0, 0, fmt.Errorf("cbor: unexpected end of data")
+	}
+	for _, c := range d.b[d.off : d.off+size] {
+		n = n<<8 | uint64(c)
+	}
+	d.off += size
+
+	// Deterministic encoding requires the shortest head.
+	var min uint64
+	switch size {
+	case 1:
+		min = 24
+	case 2:
+		min = 0x100
+	case 4:
+		min = 0x10000
+	case 8:
+		min = 0x100000000
+	}
+	if n < min {
+		return 0, 0, fmt.Errorf("cbor: non-canonical integer encoding")
+	}
+	return major, n, nil
+}
+
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.b)-d.off) {
+		return nil, fmt.Errorf("cbor: unexpected end of data")
+	}
+	s := d.b[d.off : d.off+int(n)]
+	d.off += int(n)
+	return s, nil
+}
+
+func (d *cborDecoder) value(w *bytes.Buffer, key string, depth int) error {
+	if depth > cborMaxDepth {
+		return fmt.Errorf("cbor: nesting too deep")
+	}
+
+	major, n, err := d.head()
+	if err != nil {
+		return err
+	}
+
+	switch major {
+	case cborUint:
+		d.writeInt(w, key, new(big.Int).SetUint64(n))
+	case cborNegInt:
+		v := new(big.Int).SetUint64(n)
+		v.Neg(v).Sub(v, big.NewInt(1))
+		d.writeInt(w, key, v)
+	case cborBytes:
+		b, err := d.take(n)
+		if err != nil {
+			return err
+		}
+		if !cborAddressFields[key] || len(b) != common.AddressLength {
+			return fmt.Errorf("cbor: %s: unexpected byte string", key)
+		}
+		writeJSONString(w, common.BytesToAddress(b).Hex())
+	case cborText:
+		b, err := d.take(n)
+		if err != nil {
+			return err
+		}
+		if !utf8.Valid(b) {
+			return fmt.Errorf("cbor: %s: text is not valid UTF-8", key)
+		}
+		// Anything the encoder compacts must be compact here too, or the
+		// intent would have two CBOR forms.
+		x := string(b)
+		if cborDecimalFields[key] && isCanonicalDecimal(x) {
+			return fmt.Errorf("cbor: %s: decimal must be an integer, not text", key)
+		}
+		if cborAddressFields[key] && isHexAddress0x(x) && common.HexToAddress(x).Hex() == x {
+			return fmt.Errorf("cbor: %s: checksummed address must be a byte string, not text", key)
+		}
+		writeJSONString(w, x)
+	case cborArray:
+		w.WriteByte('[')
+		for i := uint64(0); i < n; i++ {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := d.value(w, "", depth+1); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	case cborMap:
+		w.WriteByte('{')
+		var prev []byte
+		for i := uint64(0); i < n; i++ {
+			start := d.off
+			km, kn, err := d.head()
+			if err != nil {
+				return err
+			}
+			if km != cborText {
+				return fmt.Errorf("cbor: map keys must be text")
+			}
+			k, err := d.take(kn)
+			if err != nil {
+				return err
+			}
+			if !utf8.Valid(k) {
+				return fmt.Errorf("cbor: map key is not valid UTF-8")
+			}
+			encKey := d.b[start:d.off]
+			if prev != nil && bytes.Compare(prev, encKey) >= 0 {
+				return fmt.Errorf("cbor: map keys not in canonical order or duplicated: %q", k)
+			}
+			prev = encKey
+
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeJSONString(w, string(k))
+			w.WriteByte(':')
+			if err := d.value(w, string(k), depth+1); err != nil {
+				return err
+			}
+		}
+		w.WriteByte('}')
+	case cborTag:
+		if n != cborTagBignum {
+			return fmt.Errorf("cbor: unsupported tag %d", n)
+		}
+		bm, bn, err := d.head()
+		if err != nil {
+			return err
+		}
+		if bm != cborBytes {
+			return fmt.Errorf("cbor: bignum must be a byte string")
+		}
+		b, err := d.take(bn)
+		if err != nil {
+			return err
+		}
+		v := new(big.Int).SetBytes(b)
+		if len(b) == 0 || b[0] == 0 || v.IsUint64() {
+			return fmt.Errorf("cbor: non-canonical bignum")
+		}
+		d.writeInt(w, key, v)
+	case cborSimple:
+		switch n {
+		case 20:
+			w.WriteString("false")
+		case 21:
+			w.WriteString("true")
+		case 22:
+			w.WriteString("null")
+		default:
+			return fmt.Errorf("cbor: unsupported simple value %d", n)
+		}
+	}
+	return nil
+}
+
+func (d *cborDecoder) writeInt(w *bytes.Buffer, key string, v *big.Int) {
+	if cborDecimalFields[key] {
+		writeJSONString(w, v.String())
+		return
+	}
+	w.WriteString(v.String())
+}
+
+func writeJSONString(w *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	w.Write(b)
+}
+
+func isCanonicalDecimal(s string) bool {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isHexAddress0x(s string) bool {
+	if len(s) != 42 || !strings.HasPrefix(s, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
@@ -0,0 +1,149 @@
+package intent
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+const testIntent = `{"chainId":1,"from":{"index":0,"type":"bip44_index"},"fromAddress":"0x9858EfFD232B4033E47d90003D41EC34EcaEda94","kind":"ETH_SEND","maxFeePerGasWei":"30000000000","maxPriorityFeePerGasWei":"1500000000","nonce":7,"to":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed","v":1,"valueWei":"500000000000000000"}`
+
+// cborHex decodes hex with spaces, for readable test vectors.
+func cborHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("bad test vector %q: %v", s, err)
+	}
+	return b
+}
+
+func TestCBORRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace [2]string
+	}{
+		{"eth send", [2]string{}},
+		{"bignum value", [2]string{`"valueWei":"500000000000000000"`, `"valueWei":"123456789012345678901234567890"`}},
+		{"zero value", [2]string{`"valueWei":"500000000000000000"`, `"valueWei":"0"`}},
+		{"base fee", [2]string{`"v":1`, `"v":1,"baseFeeWei":"12000000000"`}},
+		{"memo", [2]string{`"v":1`, `"v":1,"memo":"rent é 😀 �"`}},
+		{"lower-case address stays text", [2]string{`0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed`, `0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := []byte(testIntent)
+			if tt.replace[0] != "" {
+				src = []byte(strings.Replace(testIntent, tt.replace[0], tt.replace[1], 1))
+			}
+			in, err := Parse(src)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			canonical, err := in.Canonical()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := JSONToCBOR(canonical)
+			if err != nil {
+				t.Fatalf("JSONToCBOR: %v", err)
+			}
+			if len(c) >= len(canonical) {
+				t.Errorf("CBOR is %d bytes, JSON %d; want it smaller", len(c), len(canonical))
+			}
+			j, err := CBORToJSON(c)
+			if err != nil {
+				t.Fatalf("CBORToJSON: %v", err)
+			}
+			back, err := Parse(j)
+			if err != nil {
+				t.Fatalf("Parse(CBORToJSON): %v", err)
+			}
+			got, err := back.Canonical()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, canonical) {
+				t.Fatalf("round trip changed the intent:\n got %s\nwant %s", got, canonical)
+			}
+			again, err := JSONToCBOR(j)
+			if err != nil {
+				t.Fatalf("JSONToCBOR(CBORToJSON): %v", err)
+			}
+			if !bytes.Equal(again, c) {
+				t.Fatalf("CBOR is not stable:\n got %x\nwant %x", again, c)
+			}
+		})
+	}
+}
+
+// Every intent has exactly one CBOR form; the decoder refuses any other.
+func TestCBORToJSONRejects(t *testing.T) {
+	tests := []struct {
+		name, cbor, wantErr string
+	}{
+		{"not a map", "81 01", "intent must be a map"},
+		{"indefinite map", "bf 61 76 01 ff", "intent must be a map"},
+		{"non-shortest int", "a1 61 76 18 01", "non-canonical integer"},
+		{"non-shortest int16", "a1 61 76 19 00 ff", "non-canonical integer"},
+		{"non-shortest map length", "b8 01 61 76 01", "non-canonical integer"},
+		{"non-shortest key length", "a1 78 01 76 01", "non-canonical integer"},
+		{"unsorted keys", "a2 61 76 01 61 61 02", "not in canonical order"},
+		{"longer key first", "a2 62 62 62 01 61 63 02", "not in canonical order"},
+		{"duplicate keys", "a2 61 76 01 61 76 01", "duplicated"},
+		{"non-text key", "a1 01 01", "map keys must be text"},
+		{"invalid UTF-8 key", "a1 61 ff 01", "map key is not valid UTF-8"},
+		{"invalid UTF-8 text", "a1 64 6d656d6f 62 ff fe", "memo: text is not valid UTF-8"},
+		{"lone surrogate text", "a1 64 6d656d6f 63 ed a0 80", "memo: text is not valid UTF-8"},
+		{"bignum that fits 64 bits", "a1 68 76616c7565576569 c2 48 0102030405060708", "non-canonical bignum"},
+		{"bignum with leading zero", "a1 68 76616c7565576569 c2 4a 00 010203040506070809", "non-canonical bignum"},
+		{"empty bignum", "a1 68 76616c7565576569 c2 40", "non-canonical bignum"},
+		{"bignum not bytes", "a1 68 76616c7565576569 c2 01", "bignum must be a byte string"},
+		{"other tag", "a1 61 76 c1 01", "unsupported tag 1"},
+		{"decimal as text", "a1 68 76616c7565576569 61 31", "decimal must be an integer"},
+		{"checksummed address as text", "a1 62 746f 78 2a " + hex.EncodeToString([]byte("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")), "must be a byte string"},
+		{"short address bytes", "a1 62 746f 53 " + strings.Repeat("11", 19), "unexpected byte string"},
+		{"bytes outside address field", "a1 64 6d656d6f 41 00", "unexpected byte string"},
+		{"float", "a1 61 76 f9 3c 00", "floats"},
+		{"undefined", "a1 61 76 f7", "unsupported simple value 23"},
+		{"indefinite array", "a1 61 76 9f 01 ff", "indefinite lengths"},
+		{"truncated", "a2 61 76 01", "unexpected end of data"},
+		{"truncated text", "a1 61 76 65 61", "unexpected end of data"},
+		{"trailing data", "a1 61 76 01 00", "trailing data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, err := CBORToJSON(cborHex(t, tt.cbor))
+			if err == nil {
+				t.Fatalf("CBORToJSON succeeded with %s, want error %q", j, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CBORToJSON error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCBORToJSONNesting(t *testing.T) {
+	// {"v": [[[...]]]} nested past the limit.
+	deep := "a1 61 76 " + strings.Repeat("81 ", cborMaxDepth+1) + "01"
+	if _, err := CBORToJSON(cborHex(t, deep)); err == nil || !strings.Contains(err.Error(), "nesting too deep") {
+		t.Fatalf("CBORToJSON error = %v, want nesting too deep", err)
+	}
+}
+
+func TestJSONToCBORIsStrict(t *testing.T) {
+	for _, src := range []string{
+		`{"v":1,"v":1}`,
+		`{"v":null}`,
+		`{"v":1.0}`,
+		`[1]`,
+		`{"v":1} {}`,
+	} {
+		if _, err := JSONToCBOR([]byte(src)); err == nil {
+			t.Errorf("JSONToCBOR(%s) succeeded, want error", src)
+		}
+	}
+}
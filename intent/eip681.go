@@ -0,0 +1,251 @@
+package intent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PaymentURIScheme is the EIP-681 URI scheme.
+const PaymentURIScheme = "ethereum:"
+
+// PaymentRequest is a parsed EIP-681 payment link. It carries only what a
+// vendor can know (recipient, amount, chain); the sender, nonce and fees are
+// supplied separately and merged with Complete.
+//
+// Supported forms:
+//
+//	ethereum:<address>[@<chainId>]?value=<wei>[&gas=<limit>]
+//	ethereum:<token>[@<chainId>]/transfer?address=<recipient>&uint256=<units>[&gas=<limit>]
+type PaymentRequest struct {
+	URI         string
+	ChainID     uint64 // 0 when the URI does not name a chain
+	Kind        string
+	To          string // recipient
+	ValueWei    string // ETH_SEND only
+	Token       string // ERC20_TRANSFER only
+	TokenAmount string // ERC20_TRANSFER only
+	GasLimit    uint64 // 0 when not given
+}
+
+// IsPaymentURI reports whether s looks like an EIP-681 URI.
+func IsPaymentURI(s string) bool {
+	return len(s) >= len(PaymentURIScheme) && strings.EqualFold(s[:len(PaymentURIScheme)], PaymentURIScheme)
+}
+
+// ParsePaymentURI parses an EIP-681 payment request. Parameters it does not
+// understand are rejected rather than ignored.
+func ParsePaymentURI(uri string) (*PaymentRequest, error) {
+	uri = strings.TrimSpace(uri)
+	if !IsPaymentURI(uri) {
+		return nil, fmt.Errorf("not an %s URI", PaymentURIScheme)
+	}
+	rest := uri[len(PaymentURIScheme):]
+	rest = strings.TrimPrefix(rest, "pay-")
+
+	path, rawQuery, _ := strings.Cut(rest, "?")
+	path, function, hasFunction := strings.Cut(path, "/")
+	target, chain, hasChain := strings.Cut(path, "@")
+
+	if !common.IsHexAddress(target) || !strings.HasPrefix(target, "0x") {
+		return nil, fmt.Errorf("payment URI target must be a 0x address (ENS names cannot be resolved offline): %q", target)
+	}
+
+	r := &PaymentRequest{URI: uri}
+	if hasChain {
+		id, err := strconv.ParseUint(chain, 10, 64)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("payment URI has invalid chain id: %q", chain)
+		}
+		r.ChainID = id
+	}
+
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("payment URI query: %w", err)
+	}
+	get := func(key string) (string, bool, error) {
+		v, ok := params[key]
+		if !ok {
+			return "", false, nil
+		}
+		if len(v) != 1 {
+			return "", false, fmt.Errorf("payment URI repeats parameter %q", key)
+		}
+		delete(params, key)
+		return v[0], true, nil
+	}
+
+	if gas, ok, err := get("gas"); err != nil {
+		return nil, err
+	} else if ok {
+		n, err := eip681Integer(gas)
+		if err != nil || !n.IsUint64() {
+			return nil, fmt.Errorf("payment URI gas: invalid value %q", gas)
+		}
+		r.GasLimit = n.Uint64()
+	}
+
+	switch {
+	case !hasFunction:
+		r.Kind = KindEthSend
+		r.To = target
+
+		value, ok, err := get("value")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("payment URI has no value")
+		}
+		n, err := eip681Integer(value)
+		if err != nil {
+			return nil, fmt.Errorf("payment URI value: %w", err)
+		}
+		r.ValueWei = n.String()
+
+	case function == "transfer":
+		r.Kind = KindERC20Transfer
+		r.Token = target
+
+		to, ok, err := get("address")
+		if err != nil {
+			return nil, err
+		}
+		if !ok || !common.IsHexAddress(to) || !strings.HasPrefix(to, "0x") {
+			return nil, fmt.Errorf("payment URI transfer needs a 0x recipient address")
+		}
+		r.To = to
+
+		amount, ok, err := get("uint256")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("payment URI transfer has no uint256 amount")
+		}
+		n, err := eip681Integer(amount)
+		if err != nil {
+			return nil, fmt.Errorf("payment URI uint256: %w", err)
+		}
+		r.TokenAmount = n.String()
+
+		if value, ok, err := get("value"); err != nil {
+			return nil, err
+		} else if ok {
+			if n, err := eip681Integer(value); err != nil || n.Sign() != 0 {
+				return nil, fmt.Errorf("payment URI transfer must not send ETH value")
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported payment URI function: %q", function)
+	}
+
+	for k := range params {
+		return nil, fmt.Errorf("unsupported payment URI parameter: %q", k)
+	}
+	return r, nil
+}
+
+// eip681Integer parses an EIP-681 number ("1000", "2.014e18") that must
+// denote a non-negative integer exactly.
+func eip681Integer(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	for _, c := range s { // no sign: amounts are never negative
+		if !(c >= '0' && c <= '9' || c == '.' || c == 'e' || c == 'E' || c == '+') {
+			return nil, fmt.Errorf("invalid number %q", s)
+		}
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || !r.IsInt() {
+		return nil, fmt.Errorf("not a whole number: %q", s)
+	}
+	return r.Num(), nil
+}
+
+// fields returns the intent fields fixed by the payment request.
+func (r *PaymentRequest) fields() map[string]any {
+	m := map[string]any{
+		"kind":       r.Kind,
+		"to":         r.To,
+		"paymentUri": r.URI,
+	}
+	if r.ChainID != 0 {
+		m["chainId"] = json.Number(strconv.FormatUint(r.ChainID, 10))
+	}
+	if r.GasLimit != 0 {
+		m["gasLimit"] = json.Number(strconv.FormatUint(r.GasLimit, 10))
+	}
+	switch r.Kind {
+	case KindEthSend:
+		m["valueWei"] = r.ValueWei
+	case KindERC20Transfer:
+		m["valueWei"] = "0"
+		m["token"] = r.Token
+		m["tokenAmount"] = r.TokenAmount
+	}
+	return m
+}
+
+// Complete merges the payment request into base, a partial intent JSON
+// object holding the pieces a URI cannot carry (from, fromAddress, nonce,
+// fees). base may be empty. A field present in both must agree.
+func (r *PaymentRequest) Complete(base []byte) ([]byte, error) {
+	m := map[string]any{}
+	if len(bytes.TrimSpace(base)) > 0 {
+		if err := CheckStrictJSON(base); err != nil {
+			return nil, fmt.Errorf("partial intent: %w", err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(base))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("partial intent: %w", err)
+		}
+	}
+	if _, ok := m["v"]; !ok {
+		m["v"] = 1
+	}
+
+	for k, v := range r.fields() {
+		if have, ok := m[k]; ok && fmt.Sprint(have) != fmt.Sprint(v) {
+			if k == "to" || k == "token" {
+				if s, isStr := have.(string); isStr && common.IsHexAddress(s) &&
+					common.HexToAddress(s) == common.HexToAddress(fmt.Sprint(v)) {
+					continue
+				}
+			}
+			return nil, fmt.Errorf("partial intent %s=%v conflicts with payment URI (%v)", k, have, v)
+		}
+		m[k] = v
+	}
+	if _, ok := m["chainId"]; !ok {
+		return nil, fmt.Errorf("payment URI has no chain id; supply one with the partial intent")
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // keep '&' in paymentUri readable
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
+// ReceiveURI returns an EIP-681 URI asking for payment to addr on chainID
+// (omitted when 0).
+func ReceiveURI(addr common.Address, chainID uint64) string {
+	s := PaymentURIScheme + addr.Hex()
+	if chainID != 0 {
+		s += "@" + strconv.FormatUint(chainID, 10)
+	}
+	return s
+}
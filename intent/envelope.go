@@ -1,35 +1,196 @@
 package intent
 
 import (
+	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"strings"
+
+	"coldsign/keys"
 )
 
 const EnvelopePrefixV1 = "coldintent:v1:"
 
+// signingDomain is prepended to the payload before a coordinator signs it,
+// so an intent signature can never be replayed as any other kind of message.
+const signingDomain = "coldsign-intent-v1\x00"
+
+// Envelope is a decoded intent envelope.
+//
+// The payload is either JSON or deterministic CBOR (see JSONToCBOR).
+// A signed envelope appends the coordinator signature after the payload:
+//
+//	coldintent:v1:<base64url(json)>.<alg>.<base64url(pubkey)>.<base64url(sig)>
+//
+// The signature covers signingDomain followed by the intent's canonical
+// JSON (see EthSendIntent.Canonical), so it does not depend on how the
+// intent was formatted. A signed envelope must carry exactly those bytes,
+// or their CBOR form.
+//
+// An EIP-681 payment URI decodes to an Envelope with PaymentRequest set and
+// no payload; the caller completes it into an intent.
+type Envelope struct {
+	Payload        []byte          // exact bytes carried (JSON or deterministic CBOR)
+	Signature      *Signature      // nil when the envelope is unsigned
+	PaymentRequest *PaymentRequest // set for ethereum: URIs
+}
+
+// IsCBOR reports whether the payload is CBOR rather than JSON.
+func (e *Envelope) IsCBOR() bool { return isCBORMap(e.Payload) }
+
+// JSON returns the intent as JSON, converting a CBOR payload.
+//
+// For a payment URI it returns the intent fields the URI fixes, which is
+// only a complete intent once merged with a partial intent (see Complete).
+func (e *Envelope) JSON() ([]byte, error) {
+	if e.PaymentRequest != nil {
+		return e.PaymentRequest.Complete(nil)
+	}
+	if e.IsCBOR() {
+		return CBORToJSON(e.Payload)
+	}
+	return e.Payload, nil
+}
+
+// Signature is a coordinator signature carried by an envelope.
+type Signature struct {
+	Key keys.PublicKey
+	Sig []byte
+}
+
 // DecodeEnvelopeOrJSON takes either:
 //   - raw JSON bytes (as string)
-//   - or an envelope: "coldintent:v1:<base64url(json)>"
+//   - or an envelope: "coldintent:v1:<base64url(json or cbor)>"
+//   - or an EIP-681 payment URI: "ethereum:0x...@1?value=..."
 //
-// It returns the underlying JSON bytes.
+// It returns the underlying JSON bytes. Any signature is ignored; use
+// DecodeEnvelope to authenticate it.
 func DecodeEnvelopeOrJSON(input string) ([]byte, error) {
+	env, err := DecodeEnvelope(input)
+	if err != nil {
+		return nil, err
+	}
+	return env.JSON()
+}
+
+// DecodeEnvelope decodes raw JSON, a (possibly signed) envelope, or an
+// EIP-681 payment URI.
+func DecodeEnvelope(input string) (*Envelope, error) {
 	s := strings.TrimSpace(input)
 	s = strings.Trim(s, "\"") // tolerate scanners that wrap in quotes
 
-	if strings.HasPrefix(s, EnvelopePrefixV1) {
-		b64 := strings.TrimPrefix(s, EnvelopePrefixV1)
-		b64 = strings.TrimSpace(b64)
+	if IsPaymentURI(s) {
+		req, err := ParsePaymentURI(s)
+		if err != nil {
+			return nil, err
+		}
+		return &Envelope{PaymentRequest: req}, nil
+	}
+
+	if !strings.HasPrefix(s, EnvelopePrefixV1) {
+		// Fallback: assume raw JSON
+		return &Envelope{Payload: []byte(s)}, nil
+	}
+
+	body := strings.TrimSpace(strings.TrimPrefix(s, EnvelopePrefixV1))
+	parts := strings.Split(body, ".")
 
-		// base64 URL encoding WITHOUT padding is ideal for QR
-		// RawURLEncoding expects no '=' padding
-		decoded, err := base64.RawURLEncoding.DecodeString(b64)
+	// base64 URL encoding WITHOUT padding is ideal for QR
+	// RawURLEncoding expects no '=' padding
+	decoded, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope base64url: %w", err)
+	}
+	env := &Envelope{Payload: decoded}
+
+	switch len(parts) {
+	case 1:
+		return env, nil
+	case 4:
+		pub, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid envelope public key: %w", err)
+		}
+		key, err := keys.NewPublicKey(parts[1], pub)
 		if err != nil {
-			return nil, fmt.Errorf("invalid envelope base64url: %w", err)
+			return nil, fmt.Errorf("invalid envelope signature: %w", err)
 		}
-		return decoded, nil
+		sig, err := base64.RawURLEncoding.DecodeString(parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid envelope signature: %w", err)
+		}
+		env.Signature = &Signature{Key: key, Sig: sig}
+		return env, nil
+	default:
+		return nil, fmt.Errorf("invalid envelope: unexpected signature section")
 	}
+}
+
+// SignIntent wraps the canonical form of in, as JSON or CBOR, in an
+// envelope signed by key.
+func SignIntent(in *EthSendIntent, asCBOR bool, key *keys.PrivateKey) (*Envelope, error) {
+	canonical, err := in.Canonical()
+	if err != nil {
+		return nil, err
+	}
+	payload := canonical
+	if asCBOR {
+		if payload, err = JSONToCBOR(canonical); err != nil {
+			return nil, err
+		}
+	}
+	sig, err := key.Sign(signedMessage(canonical))
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		Payload:   payload,
+		Signature: &Signature{Key: key.Public(), Sig: sig},
+	}, nil
+}
+
+// signedIntent returns the canonical intent a signature over e covers. It
+// fails unless the payload is a valid intent in canonical form, as JSON or
+// CBOR.
+func (e *Envelope) signedIntent() ([]byte, error) {
+	b, err := e.JSON()
+	if err != nil {
+		return nil, err
+	}
+	in, err := Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	canonical, err := in.Canonical()
+	if err != nil {
+		return nil, err
+	}
+	if e.IsCBOR() {
+		b, err = JSONToCBOR(canonical)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(e.Payload, b) {
+			return nil, errors.New("signed payload is not in canonical form")
+		}
+	} else if !bytes.Equal(e.Payload, canonical) {
+		return nil, errors.New("signed payload is not in canonical form")
+	}
+	return canonical, nil
+}
+
+// String encodes e in its textual envelope form.
+func (e *Envelope) String() string {
+	s := EnvelopePrefixV1 + base64.RawURLEncoding.EncodeToString(e.Payload)
+	if e.Signature != nil {
+		s += "." + e.Signature.Key.Alg +
+			"." + base64.RawURLEncoding.EncodeToString(e.Signature.Key.Key) +
+			"." + base64.RawURLEncoding.EncodeToString(e.Signature.Sig)
+	}
+	return s
+}
 
-	// Fallback: assume raw JSON
-	return []byte(s), nil
+func signedMessage(canonical []byte) []byte {
+	return append([]byte(signingDomain), canonical...)
 }
@@ -0,0 +1,52 @@
+package intent
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// transferSelector is the 4-byte selector of transfer(address,uint256).
+var transferSelector = []byte{0xa9, 0x05, 0x9c, 0xbb}
+
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+func (in *EthSendIntent) validateERC20Transfer(ps *problems) {
+	if !common.IsHexAddress(in.Token) {
+		ps.add("/token", "invalid address: %q", in.Token)
+	} else if common.HexToAddress(in.Token) == (common.Address{}) {
+		ps.add("/token", "must not be the zero address")
+	}
+
+	if amount, err := parseUintDecimal(in.TokenAmount); err != nil {
+		ps.add("/tokenAmount", "%v", err)
+	} else if amount.Cmp(maxUint256) > 0 {
+		ps.add("/tokenAmount", "exceeds uint256")
+	}
+
+	if v, err := parseUintDecimal(in.ValueWei); err == nil && v.Sign() != 0 {
+		ps.add("/valueWei", "must be 0 for %s", KindERC20Transfer)
+	}
+	if in.GasLimit <= EthTransferGas {
+		ps.add("/gasLimit", "must be greater than %d for %s", EthTransferGas, KindERC20Transfer)
+	}
+}
+
+// TransferCalldata returns the ABI-encoded transfer(To, TokenAmount) call
+// for an ERC20_TRANSFER intent.
+func (in *EthSendIntent) TransferCalldata() ([]byte, error) {
+	if in.Kind != KindERC20Transfer {
+		return nil, fmt.Errorf("not a %s intent", KindERC20Transfer)
+	}
+	amount, err := parseUintDecimal(in.TokenAmount)
+	if err != nil {
+		return nil, fmt.Errorf("tokenAmount: %w", err)
+	}
+
+	data := make([]byte, 0, 4+32+32)
+	data = append(data, transferSelector...)
+	data = append(data, common.LeftPadBytes(common.HexToAddress(in.To).Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(amount.Bytes(), 32)...)
+	return data, nil
+}
@@ -1,92 +1,214 @@
 package intent
 
 import (
-	"encoding/json"
 	"fmt"
 	"math/big"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 )
 
+// Intent kinds.
+const (
+	KindEthSend       = "ETH_SEND"
+	KindERC20Transfer = "ERC20_TRANSFER"
+)
+
+// EthTransferGas is the fixed gas limit of a plain ETH transfer.
+const EthTransferGas = 21000
+
 type FromRef struct {
 	Type  string `json:"type"` // "bip44_index"
 	Index uint32 `json:"index"`
 }
 
+// EthSendIntent describes one transaction to sign. ETH_SEND moves ValueWei
+// to To. ERC20_TRANSFER calls transfer(To, TokenAmount) on Token with a zero
+// ETH value; To is still the human-meaningful recipient.
 type EthSendIntent struct {
 	V                       int     `json:"v"`
-	Kind                    string  `json:"kind"` // must be "ETH_SEND"
+	Kind                    string  `json:"kind"` // "ETH_SEND" or "ERC20_TRANSFER"
 	ChainID                 uint64  `json:"chainId"`
 	From                    FromRef `json:"from"`
 	FromAddress             string  `json:"fromAddress"` // expected derived address (0x...), required for safety
-	To                      string  `json:"to"`
+	To                      string  `json:"to,omitempty"` // required unless ToLabel is given
 	ValueWei                string  `json:"valueWei"`
 	Nonce                   uint64  `json:"nonce"`
 	MaxFeePerGasWei         string  `json:"maxFeePerGasWei"`
 	MaxPriorityFeePerGasWei string  `json:"maxPriorityFeePerGasWei"`
+
+	// Unit-aware alternatives to the wei fields (see amounts.go)
+	Value          string `json:"value,omitempty"`          // e.g. "0.01 ETH"
+	MaxFee         string `json:"maxFee,omitempty"`         // e.g. "35 gwei"
+	MaxPriorityFee string `json:"maxPriorityFee,omitempty"` // e.g. "1.5 gwei"
+
+	// ERC20_TRANSFER only
+	Token       string `json:"token,omitempty"`       // token contract address
+	TokenAmount string `json:"tokenAmount,omitempty"` // amount in token base units
+	GasLimit    uint64 `json:"gasLimit,omitempty"`    // required for contract calls
+
+	// ToLabel names the recipient in the cold machine's address book, which
+	// resolves it; To, if also given, must match the entry.
+	ToLabel string `json:"toLabel,omitempty"`
+
+	// PaymentURI is the EIP-681 request this intent was built from, if any.
+	PaymentURI string `json:"paymentUri,omitempty"`
+
+	// ReplacesTxHash marks a deliberate replacement (or cancel) of a
+	// transaction already signed for the same sender and nonce. Without it,
+	// a second transaction for a used nonce is refused.
+	ReplacesTxHash string `json:"replacesTxHash,omitempty"`
+
+	// BaseFeeWei is the base fee the coordinator saw when it built the
+	// intent. Covered by the coordinator signature, it lets the policy judge
+	// maxFeePerGas against network conditions the cold machine cannot see.
+	BaseFeeWei string `json:"baseFeeWei,omitempty"`
+
+	// Optional metadata, shown in the review (see metadata.go)
+	IntentID    string `json:"intentId,omitempty"`    // coordinator-assigned identifier
+	CreatedAt   string `json:"createdAt,omitempty"`   // RFC 3339
+	ValidUntil  string `json:"validUntil,omitempty"`  // RFC 3339; refused after this time
+	RequestedBy string `json:"requestedBy,omitempty"` // free text
+	Memo        string `json:"memo,omitempty"`        // free text
 }
 
-func ParseEthSend(b []byte) (*EthSendIntent, error) {
+// Parse decodes and validates an intent of any supported kind. Validation
+// failures are reported as a *ValidationError listing every problem.
+func Parse(b []byte) (*EthSendIntent, error) {
 	var in EthSendIntent
-	if err := json.Unmarshal(b, &in); err != nil {
+	if err := decodeStrict(b, &in); err != nil {
 		return nil, err
 	}
 
-	if in.V != 1 {
-		return nil, fmt.Errorf("unsupported intent version: %d", in.V)
-	}
-	if in.Kind != "ETH_SEND" {
-		return nil, fmt.Errorf("unsupported intent kind: %s", in.Kind)
-	}
-	if in.From.Type != "bip44_index" {
-		return nil, fmt.Errorf("unsupported from.type: %s", in.From.Type)
+	var ps problems
+	in.normalizeAmounts(&ps)
+	in.validate(&ps)
+	if err := ps.err(); err != nil {
+		return nil, err
 	}
+	return &in, nil
+}
 
-	if err := in.Validate(); err != nil {
+// ParseEthSend decodes and validates an ETH_SEND intent.
+func ParseEthSend(b []byte) (*EthSendIntent, error) {
+	in, err := Parse(b)
+	if err != nil {
 		return nil, err
 	}
-
-	return &in, nil
+	if in.Kind != KindEthSend {
+		return nil, invalid("/kind", "unsupported intent kind: %s", in.Kind)
+	}
+	return in, nil
 }
 
 func parseUintDecimal(s string) (*big.Int, error) {
-	x, ok := new(big.Int).SetString(s, 10)
-	if !ok {
-		return nil, fmt.Errorf("invalid decimal integer: %q", s)
-	}
-	if x.Sign() < 0 {
+	if strings.HasPrefix(s, "-") {
 		return nil, fmt.Errorf("negative value not allowed: %q", s)
 	}
+	// Digits only, no sign or leading zeros: one spelling per value.
+	if !isCanonicalDecimal(s) {
+		return nil, fmt.Errorf("invalid decimal integer: %q", s)
+	}
+	x, _ := new(big.Int).SetString(s, 10)
 	return x, nil
 }
 
+// Gas returns the gas limit the transaction will be built with.
+func (in *EthSendIntent) Gas() uint64 {
+	if in.Kind == KindEthSend {
+		return EthTransferGas
+	}
+	return in.GasLimit
+}
+
+// Validate checks every field and returns a *ValidationError listing all
+// problems, or nil.
 func (in *EthSendIntent) Validate() error {
+	var ps problems
+	in.validate(&ps)
+	return ps.err()
+}
+
+func (in *EthSendIntent) validate(ps *problems) {
+
+	if in.V != 1 {
+		ps.add("/v", "unsupported intent version: %d", in.V)
+	}
+	if in.Kind != KindEthSend && in.Kind != KindERC20Transfer {
+		ps.add("/kind", "unsupported intent kind: %q", in.Kind)
+	}
+	if in.From.Type != "bip44_index" {
+		ps.add("/from/type", "unsupported from.type: %q", in.From.Type)
+	}
+
 	// Address checks
-	if !common.IsHexAddress(in.To) {
-		return fmt.Errorf("invalid to address: %s", in.To)
+	if in.ToLabel != "" && !ValidLabel(in.ToLabel) {
+		ps.add("/toLabel", "must be 1 to %d letters, digits or . _ : -", maxLabelLen)
 	}
-	to := common.HexToAddress(in.To)
-	if to == (common.Address{}) {
-		return fmt.Errorf("to address must not be zero address")
+	if in.To == "" {
+		if in.ToLabel == "" {
+			ps.add("/to", "is required (or toLabel)")
+		}
+	} else if !common.IsHexAddress(in.To) {
+		ps.add("/to", "invalid address: %q", in.To)
+	} else if common.HexToAddress(in.To) == (common.Address{}) {
+		ps.add("/to", "must not be the zero address")
 	}
 	if in.FromAddress == "" {
-    return fmt.Errorf("fromAddress is required")
-	}
-	if !common.IsHexAddress(in.FromAddress) {
-			return fmt.Errorf("invalid fromAddress: %s", in.FromAddress)
+		ps.add("/fromAddress", "is required")
+	} else if !common.IsHexAddress(in.FromAddress) {
+		ps.add("/fromAddress", "invalid address: %q", in.FromAddress)
 	}
 
+	// Numeric checks. A wei field left empty because its unit-aware form
+	// was invalid has already been reported.
+	for _, f := range in.amountFields() {
+		switch {
+		case *f.wei == "" && *f.written == "":
+			ps.add(f.weiPath, "is required (or %s)", f.path[1:])
+		case *f.wei == "":
+		default:
+			if _, err := parseUintDecimal(*f.wei); err != nil {
+				ps.add(f.weiPath, "%v", err)
+			}
+		}
+	}
 
-	// Numeric checks
-	if _, err := parseUintDecimal(in.ValueWei); err != nil {
-		return fmt.Errorf("valueWei: %w", err)
+	if in.BaseFeeWei != "" {
+		if _, err := parseUintDecimal(in.BaseFeeWei); err != nil {
+			ps.add("/baseFeeWei", "%v", err)
+		}
 	}
-	if _, err := parseUintDecimal(in.MaxFeePerGasWei); err != nil {
-		return fmt.Errorf("maxFeePerGasWei: %w", err)
+	if in.ReplacesTxHash != "" && !isTxHash(in.ReplacesTxHash) {
+		ps.add("/replacesTxHash", "must be 0x followed by 64 hex digits")
 	}
-	if _, err := parseUintDecimal(in.MaxPriorityFeePerGasWei); err != nil {
-		return fmt.Errorf("maxPriorityFeePerGasWei: %w", err)
+
+	in.validateMetadata(ps)
+
+	switch in.Kind {
+	case KindERC20Transfer:
+		in.validateERC20Transfer(ps)
+	case KindEthSend:
+		if in.Token != "" {
+			ps.add("/token", "only allowed for %s", KindERC20Transfer)
+		}
+		if in.TokenAmount != "" {
+			ps.add("/tokenAmount", "only allowed for %s", KindERC20Transfer)
+		}
+		if in.GasLimit != 0 && in.GasLimit != EthTransferGas {
+			ps.add("/gasLimit", "must be %d for %s", EthTransferGas, KindEthSend)
+		}
 	}
+}
 
-	return nil
+func isTxHash(s string) bool {
+	if len(s) != 66 || !strings.HasPrefix(s, "0x") {
+		return false
+	}
+	for _, c := range s[2:] {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,99 @@
+package intent
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// Metadata limits. Free text is shown in the review, so it is kept short;
+// it is escaped for the terminal (helpers.SafeText) rather than restricted.
+const (
+	maxIntentIDLen    = 64
+	maxRequestedByLen = 128
+	maxMemoLen        = 512
+)
+
+func (in *EthSendIntent) validateMetadata(ps *problems) {
+	if len(in.IntentID) > maxIntentIDLen {
+		ps.add("/intentId", "longer than %d characters", maxIntentIDLen)
+	}
+	for _, c := range in.IntentID {
+		if !isIntentIDChar(c) {
+			ps.add("/intentId", "only letters, digits and . _ : - are allowed")
+			break
+		}
+	}
+
+	if len(in.RequestedBy) > maxRequestedByLen {
+		ps.add("/requestedBy", "longer than %d bytes", maxRequestedByLen)
+	}
+	if !utf8.ValidString(in.RequestedBy) {
+		ps.add("/requestedBy", "must be valid UTF-8")
+	}
+	if len(in.Memo) > maxMemoLen {
+		ps.add("/memo", "longer than %d bytes", maxMemoLen)
+	}
+	if !utf8.ValidString(in.Memo) {
+		ps.add("/memo", "must be valid UTF-8")
+	}
+
+	created, err := parseTime(in.CreatedAt)
+	if err != nil {
+		ps.add("/createdAt", "%v", err)
+	}
+	until, err := parseTime(in.ValidUntil)
+	if err != nil {
+		ps.add("/validUntil", "%v", err)
+	}
+	if !created.IsZero() && !until.IsZero() && !until.After(created) {
+		ps.add("/validUntil", "must be after createdAt")
+	}
+}
+
+// maxLabelLen bounds address book labels (see ValidLabel).
+const maxLabelLen = 64
+
+// ValidLabel reports whether s can be an address book label: 1 to 64
+// letters, digits or . _ : -, the same alphabet as intent IDs. Labels are
+// typed and compared by operators, so nothing else is allowed.
+func ValidLabel(s string) bool {
+	if s == "" || len(s) > maxLabelLen {
+		return false
+	}
+	for _, c := range s {
+		if !isIntentIDChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIntentIDChar(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '.' || c == '_' || c == ':' || c == '-'
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("want an RFC 3339 timestamp, got %q", s)
+	}
+	return t, nil
+}
+
+// CreatedTime returns createdAt, or the zero time when unset.
+func (in *EthSendIntent) CreatedTime() time.Time {
+	t, _ := parseTime(in.CreatedAt)
+	return t
+}
+
+// ExpiryTime returns validUntil, or the zero time when the intent does not
+// expire.
+func (in *EthSendIntent) ExpiryTime() time.Time {
+	t, _ := parseTime(in.ValidUntil)
+	return t
+}
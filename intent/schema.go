@@ -0,0 +1,27 @@
+package intent
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+)
+
+// Published JSON Schemas (draft 2020-12), one per intent kind, for tools
+// that build intents on the online side. They describe what Parse accepts;
+// Parse remains the authority.
+//
+//go:embed schema/*.schema.json
+var schemaFS embed.FS
+
+// Kinds lists the supported intent kinds.
+var Kinds = []string{KindEthSend, KindERC20Transfer}
+
+// Schema returns the JSON Schema for an intent kind.
+func Schema(kind string) ([]byte, error) {
+	for _, k := range Kinds {
+		if k == kind {
+			return schemaFS.ReadFile("schema/" + strings.ToLower(kind) + ".schema.json")
+		}
+	}
+	return nil, fmt.Errorf("unknown intent kind: %q (want one of %s)", kind, strings.Join(Kinds, ", "))
+}
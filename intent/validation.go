@@ -0,0 +1,97 @@
+package intent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Problem is one reason an intent is invalid. Path is a JSON pointer
+// (RFC 6901) to the offending field, "" for the intent as a whole.
+type Problem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return p.Path + ": " + p.Message
+}
+
+// ValidationError lists every problem found in an intent, so a tool
+// builder can fix them all in one pass.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].String()
+	}
+	parts := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		parts[i] = p.String()
+	}
+	return fmt.Sprintf("%d problems: %s", len(e.Problems), strings.Join(parts, "; "))
+}
+
+// problems collects validation failures.
+type problems []Problem
+
+func (ps *problems) add(path, format string, args ...any) {
+	*ps = append(*ps, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns nil when nothing was collected, or a *ValidationError.
+func (ps problems) err() error {
+	if len(ps) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: ps}
+}
+
+// invalid returns a *ValidationError with a single problem.
+func invalid(path, format string, args ...any) error {
+	var ps problems
+	ps.add(path, format, args...)
+	return ps.err()
+}
+
+// pointer builds a JSON pointer from object keys.
+func pointer(keys ...string) string {
+	var b strings.Builder
+	for _, k := range keys {
+		k = strings.ReplaceAll(k, "~", "~0")
+		k = strings.ReplaceAll(k, "/", "~1")
+		b.WriteString("/" + k)
+	}
+	return b.String()
+}
+
+// decodeProblem turns an encoding/json type error into a Problem-carrying
+// error; other errors are returned unchanged.
+func decodeProblem(err error) error {
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &te) && te.Field != "" {
+		return invalid(pointer(strings.Split(te.Field, ".")...), "must be %s, got JSON %s", jsonKind(te.Type.Kind().String()), te.Value)
+	}
+	return err
+}
+
+func jsonKind(goKind string) string {
+	switch {
+	case strings.HasPrefix(goKind, "uint"):
+		return "an unsigned integer (" + goKind + ")"
+	case strings.HasPrefix(goKind, "int"):
+		return "an integer"
+	case goKind == "string":
+		return "a string"
+	case goKind == "struct":
+		return "an object"
+	default:
+		return goKind
+	}
+}
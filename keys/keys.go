@@ -0,0 +1,205 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"coldsign/helpers"
+)
+
+// Supported signature algorithms.
+const (
+	AlgEd25519   = "ed25519"
+	AlgSecp256k1 = "secp256k1"
+)
+
+// PublicKey is a verification key for one of the supported algorithms.
+// Ed25519 keys are 32 bytes; secp256k1 keys are 33-byte compressed points.
+type PublicKey struct {
+	Alg string
+	Key []byte
+}
+
+// ParsePublicKey decodes a hex public key for alg.
+func ParsePublicKey(alg, hexKey string) (PublicKey, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(hexKey), "0x"))
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("invalid %s public key hex: %w", alg, err)
+	}
+	return NewPublicKey(alg, b)
+}
+
+// NewPublicKey checks that key is well formed for alg.
+func NewPublicKey(alg string, key []byte) (PublicKey, error) {
+	switch alg {
+	case AlgEd25519:
+		if len(key) != ed25519.PublicKeySize {
+			return PublicKey{}, fmt.Errorf("ed25519 public key must be %d bytes", ed25519.PublicKeySize)
+		}
+	case AlgSecp256k1:
+		if len(key) != 33 {
+			return PublicKey{}, fmt.Errorf("secp256k1 public key must be 33 bytes (compressed)")
+		}
+		if _, err := crypto.DecompressPubkey(key); err != nil {
+			return PublicKey{}, fmt.Errorf("invalid secp256k1 public key: %w", err)
+		}
+	default:
+		return PublicKey{}, fmt.Errorf("unsupported key algorithm: %q", alg)
+	}
+	return PublicKey{Alg: alg, Key: append([]byte(nil), key...)}, nil
+}
+
+// Hex returns the public key bytes as lowercase hex.
+func (k PublicKey) Hex() string { return hex.EncodeToString(k.Key) }
+
+// Fingerprint returns a short identifier for displaying the key to an operator.
+func (k PublicKey) Fingerprint() string {
+	sum := sha256.Sum256(append([]byte(k.Alg+":"), k.Key...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Equal reports whether k and other are the same key.
+func (k PublicKey) Equal(other PublicKey) bool {
+	return k.Alg == other.Alg && string(k.Key) == string(other.Key)
+}
+
+// Verify reports whether sig is a valid signature of msg under k.
+func (k PublicKey) Verify(msg, sig []byte) bool {
+	switch k.Alg {
+	case AlgEd25519:
+		return len(k.Key) == ed25519.PublicKeySize && ed25519.Verify(ed25519.PublicKey(k.Key), msg, sig)
+	case AlgSecp256k1:
+		if len(sig) != 64 {
+			return false
+		}
+		return crypto.VerifySignature(k.Key, crypto.Keccak256(msg), sig)
+	default:
+		return false
+	}
+}
+
+// PrivateKey is a signing key for one of the supported algorithms.
+type PrivateKey struct {
+	Alg string
+	ed  ed25519.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+// Generate creates a new random signing key.
+func Generate(alg string) (*PrivateKey, error) {
+	switch alg {
+	case AlgEd25519:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return &PrivateKey{Alg: alg, ed: priv}, nil
+	case AlgSecp256k1:
+		priv, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		return &PrivateKey{Alg: alg, ec: priv}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm: %q", alg)
+	}
+}
+
+// Public returns the verification key for k.
+func (k *PrivateKey) Public() PublicKey {
+	switch k.Alg {
+	case AlgEd25519:
+		return PublicKey{Alg: k.Alg, Key: append([]byte(nil), k.ed.Public().(ed25519.PublicKey)...)}
+	default:
+		return PublicKey{Alg: k.Alg, Key: crypto.CompressPubkey(&k.ec.PublicKey)}
+	}
+}
+
+// Sign signs msg. secp256k1 signatures are 64-byte r||s over keccak256(msg).
+func (k *PrivateKey) Sign(msg []byte) ([]byte, error) {
+	switch k.Alg {
+	case AlgEd25519:
+		return ed25519.Sign(k.ed, msg), nil
+	case AlgSecp256k1:
+		sig, err := crypto.Sign(crypto.Keccak256(msg), k.ec)
+		if err != nil {
+			return nil, err
+		}
+		return sig[:64], nil
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm: %q", k.Alg)
+	}
+}
+
+// KeyFile is the on-disk format of a private key:
+//
+//	{"alg":"ed25519","privateKey":"<hex>","publicKey":"<hex>"}
+//
+// This package cannot use the strict JSON decoder (package intent imports
+// it), so callers decode the file with intent.DecodeStrict and call Key.
+type KeyFile struct {
+	Alg        string `json:"alg"`
+	PrivateKey string `json:"privateKey"`
+	PublicKey  string `json:"publicKey"`
+}
+
+// Save writes k to path with owner-only permissions. It refuses to
+// overwrite an existing file.
+func (k *PrivateKey) Save(path string) error {
+	kf := KeyFile{Alg: k.Alg, PublicKey: k.Public().Hex()}
+	switch k.Alg {
+	case AlgEd25519:
+		kf.PrivateKey = hex.EncodeToString(k.ed.Seed())
+	default:
+		kf.PrivateKey = hex.EncodeToString(crypto.FromECDSA(k.ec))
+	}
+
+	b, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return helpers.WriteNewFile(path, append(b, '\n'), 0o600)
+}
+
+// Key returns the private key kf holds. Its publicKey must be the key's
+// public key.
+func (kf *KeyFile) Key() (*PrivateKey, error) {
+	raw, err := hex.DecodeString(kf.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("/privateKey: invalid hex")
+	}
+
+	var k *PrivateKey
+	switch kf.Alg {
+	case AlgEd25519:
+		if len(raw) != ed25519.SeedSize {
+			return nil, fmt.Errorf("/privateKey: ed25519 seed must be %d bytes", ed25519.SeedSize)
+		}
+		k = &PrivateKey{Alg: kf.Alg, ed: ed25519.NewKeyFromSeed(raw)}
+	case AlgSecp256k1:
+		priv, err := crypto.ToECDSA(raw)
+		if err != nil {
+			return nil, fmt.Errorf("/privateKey: %w", err)
+		}
+		k = &PrivateKey{Alg: kf.Alg, ec: priv}
+	default:
+		return nil, fmt.Errorf("/alg: unsupported key algorithm: %q", kf.Alg)
+	}
+
+	pub, err := ParsePublicKey(kf.Alg, kf.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("/publicKey: %w", err)
+	}
+	if !pub.Equal(k.Public()) {
+		return nil, fmt.Errorf("/publicKey: does not match the private key")
+	}
+	return k, nil
+}
@@ -0,0 +1,320 @@
+// Package ledger is the local, append-only record of the transactions
+// coldsign has signed. It lives on the cold machine and is read back to
+// enforce rolling spending limits; nothing ever rewrites or removes a
+// record. The ledger also guards nonces: each (chain, sender, nonce) gets
+// one transaction unless a replacement is explicitly requested.
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"coldsign/intent"
+)
+
+// Version is the record format version.
+const Version = 1
+
+// AssetETH names the chain's native asset. Tokens are named by their
+// checksummed contract address.
+const AssetETH = "ETH"
+
+// Record is one signed transaction, stored as a line of JSON:
+//
+//	{"v":1,"time":"2026-10-19T09:30:00Z","chainId":1,"from":"0x...","to":"0x...","nonce":7,"asset":"ETH","amount":"1500000000000000000","signingHash":"0x...","txHash":"0x...","intentHash":"..."}
+type Record struct {
+	V       int    `json:"v"`
+	Time    string `json:"time"` // RFC 3339, UTC, by the local clock
+	ChainID uint64 `json:"chainId"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Nonce   uint64 `json:"nonce"`
+	Asset   string `json:"asset"`  // AssetETH or a token address
+	Amount  string `json:"amount"` // wei, or token base units
+
+	// SigningHash identifies the unsigned transaction (see
+	// signer.SigningHash); TxHash identifies the signed one.
+	SigningHash string `json:"signingHash"`
+	TxHash      string `json:"txHash"`
+	IntentHash  string `json:"intentHash"`
+	Replaces    string `json:"replacesTxHash,omitempty"` // from the intent
+
+	time   time.Time
+	amount *big.Int
+}
+
+// Asset returns the asset an intent moves and the amount: value in wei for
+// ETH_SEND, token base units for ERC20_TRANSFER.
+func Asset(in *intent.EthSendIntent) (string, string) {
+	if in.Kind == intent.KindERC20Transfer {
+		return common.HexToAddress(in.Token).Hex(), in.TokenAmount
+	}
+	return AssetETH, in.ValueWei
+}
+
+// NewRecord describes a signed intent, timestamped now.
+func NewRecord(in *intent.EthSendIntent, intentHash, signingHash, txHash string, now time.Time) Record {
+	asset, amount := Asset(in)
+	return Record{
+		V:           Version,
+		Time:        now.UTC().Format(time.RFC3339),
+		ChainID:     in.ChainID,
+		From:        common.HexToAddress(in.FromAddress).Hex(),
+		To:          common.HexToAddress(in.To).Hex(),
+		Nonce:       in.Nonce,
+		Asset:       asset,
+		Amount:      amount,
+		SigningHash: signingHash,
+		TxHash:      txHash,
+		IntentHash:  intentHash,
+		Replaces:    in.ReplacesTxHash,
+	}
+}
+
+func (r *Record) check() error {
+	if r.V != Version {
+		return fmt.Errorf("unsupported record version %d (want %d)", r.V, Version)
+	}
+	t, err := time.Parse(time.RFC3339, r.Time)
+	if err != nil {
+		return fmt.Errorf("invalid time %q", r.Time)
+	}
+	amount, ok := new(big.Int).SetString(r.Amount, 10)
+	if !ok || amount.Sign() < 0 {
+		return fmt.Errorf("invalid amount %q", r.Amount)
+	}
+	if !common.IsHexAddress(r.From) {
+		return fmt.Errorf("invalid from %q", r.From)
+	}
+	if r.SigningHash == "" {
+		return fmt.Errorf("signingHash is required")
+	}
+	r.time, r.amount = t, amount
+	return nil
+}
+
+// Ledger is an opened ledger file. Records appended through it are also
+// counted by later queries, so several intents signed in one run see each
+// other.
+type Ledger struct {
+	path    string
+	records []Record
+}
+
+// Open reads the ledger at path. A missing file is an empty ledger; it is
+// created by the first Append. Any unreadable line is an error: limits
+// cannot be enforced from a ledger that cannot be read in full.
+func Open(path string) (*Ledger, error) {
+	l := &Ledger{path: path}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 0, 4096), 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var r Record
+		if err := intent.DecodeStrict(line, &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if err := r.check(); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		l.records = append(l.records, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		return nil, fmt.Errorf("%s: last record is incomplete (interrupted write?)", path)
+	}
+	return l, nil
+}
+
+// Path returns the ledger file path.
+func (l *Ledger) Path() string { return l.path }
+
+// Records returns the records in the order they were signed.
+func (l *Ledger) Records() []Record { return l.records }
+
+// Append adds r to the ledger file and syncs it to disk. The file and its
+// directory are created on first use, readable by the owner only.
+func (l *Ledger) Append(r Record) error {
+	if err := r.check(); err != nil {
+		return err
+	}
+	line, err := jsonLine(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	l.records = append(l.records, r)
+	return nil
+}
+
+// Spent sums the amount of asset sent from from on chainID at or after
+// since. Records timestamped later than the local clock still count. Only
+// one transaction per nonce can be mined, so a transaction signed again or
+// replaced counts once, at the largest amount signed for its nonce.
+func (l *Ledger) Spent(chainID uint64, from common.Address, asset string, since time.Time) *big.Int {
+	return l.spent(chainID, from, asset, since, nil)
+}
+
+// SpentWith is Spent with a transaction for nonce, sending amount, added
+// as if it were already recorded.
+func (l *Ledger) SpentWith(chainID uint64, from common.Address, asset string, since time.Time, nonce uint64, amount *big.Int) *big.Int {
+	return l.spent(chainID, from, asset, since, map[uint64]*big.Int{nonce: amount})
+}
+
+func (l *Ledger) spent(chainID uint64, from common.Address, asset string, since time.Time, byNonce map[uint64]*big.Int) *big.Int {
+	if byNonce == nil {
+		byNonce = make(map[uint64]*big.Int)
+	}
+	for i := range l.records {
+		r := &l.records[i]
+		if r.ChainID != chainID || !strings.EqualFold(r.Asset, asset) || common.HexToAddress(r.From) != from {
+			continue
+		}
+		if r.time.Before(since) {
+			continue
+		}
+		if max := byNonce[r.Nonce]; max == nil || r.amount.Cmp(max) > 0 {
+			byNonce[r.Nonce] = r.amount
+		}
+	}
+	sum := new(big.Int)
+	for _, amount := range byNonce {
+		sum.Add(sum, amount)
+	}
+	return sum
+}
+
+// NonceCheck is what the ledger knows about an intent's nonce.
+type NonceCheck struct {
+	Nonce    uint64
+	Previous []Record // already signed for this chain, sender and nonce
+	Replaces *Record  // the record named by the intent's replacesTxHash
+	Highest  uint64   // highest nonce signed for this chain and sender
+	Seen     bool     // whether any nonce has been signed for them
+}
+
+// Resign reports whether the intent builds a transaction already signed
+// for its nonce, so signing it again changes nothing.
+func (c *NonceCheck) Resign(signingHash string) bool {
+	for _, r := range c.Previous {
+		if r.SigningHash == signingHash {
+			return true
+		}
+	}
+	return false
+}
+
+// Warning returns a non-empty warning when the nonce skips past the next
+// one after the highest signed: the transaction cannot be mined until the
+// missing nonces are.
+func (c *NonceCheck) Warning() string {
+	if !c.Seen || c.Nonce <= c.Highest+1 {
+		return ""
+	}
+	return fmt.Sprintf("nonce gap: nonce %d, but the highest nonce signed for this sender is %d; nonces %d to %d must be mined first",
+		c.Nonce, c.Highest, c.Highest+1, c.Nonce-1)
+}
+
+// CheckNonce looks up the intent's chain, sender and nonce. signingHash
+// identifies the transaction the intent builds. Signing the same
+// transaction again is allowed; a different transaction for a nonce that
+// already has one is refused unless the intent names one of them in
+// replacesTxHash.
+func (l *Ledger) CheckNonce(in *intent.EthSendIntent, signingHash string) (*NonceCheck, error) {
+	from := common.HexToAddress(in.FromAddress)
+	c := &NonceCheck{Nonce: in.Nonce}
+	var replaced *Record // any record with the replacesTxHash, for errors
+	for i := range l.records {
+		r := &l.records[i]
+		if in.ReplacesTxHash != "" && strings.EqualFold(r.TxHash, in.ReplacesTxHash) {
+			replaced = r
+		}
+		if r.ChainID != in.ChainID || common.HexToAddress(r.From) != from {
+			continue
+		}
+		if !c.Seen || r.Nonce > c.Highest {
+			c.Highest = r.Nonce
+		}
+		c.Seen = true
+		if r.Nonce != in.Nonce {
+			continue
+		}
+		c.Previous = append(c.Previous, *r)
+	}
+
+	if in.ReplacesTxHash != "" {
+		for i := range c.Previous {
+			if strings.EqualFold(c.Previous[i].TxHash, in.ReplacesTxHash) {
+				c.Replaces = &c.Previous[i]
+			}
+		}
+
+		switch {
+		case c.Replaces != nil:
+			return c, nil
+		case replaced != nil:
+			return c, fmt.Errorf("replacesTxHash %s was signed for chain %d, %s, nonce %d; this intent is nonce %d",
+				in.ReplacesTxHash, replaced.ChainID, replaced.From, replaced.Nonce, in.Nonce)
+		default:
+			return c, fmt.Errorf("replacesTxHash %s is not in the ledger; only transactions signed here can be replaced", in.ReplacesTxHash)
+		}
+	}
+	if len(c.Previous) > 0 && !c.Resign(signingHash) {
+		prev := c.Previous[len(c.Previous)-1]
+		return c, fmt.Errorf("nonce %d of %s on chain %d was already signed for a different transaction (%s at %s); set replacesTxHash to replace or cancel it",
+			in.Nonce, from.Hex(), in.ChainID, prev.TxHash, prev.Time)
+	}
+	return c, nil
+}
+
+// jsonLine encodes r as one line of JSON, newline included.
+func jsonLine(r Record) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,213 @@
+package ledger
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"coldsign/intent"
+)
+
+const (
+	testFrom  = "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+	testTo    = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	testOther = "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+)
+
+var testNow = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func ethSend(nonce uint64, valueWei string) *intent.EthSendIntent {
+	return &intent.EthSendIntent{
+		Kind:        intent.KindEthSend,
+		ChainID:     1,
+		FromAddress: testFrom,
+		To:          testTo,
+		Nonce:       nonce,
+		ValueWei:    valueWei,
+	}
+}
+
+// record signs in at now - ago with a transaction named by tag.
+func record(in *intent.EthSendIntent, tag string, ago time.Duration) Record {
+	return NewRecord(in, "intent-"+tag, "0xsigning-"+tag, "0xtx-"+tag, testNow.Add(-ago))
+}
+
+func openTemp(t *testing.T, records ...Record) *Ledger {
+	t.Helper()
+	l, err := Open(filepath.Join(t.TempDir(), "state", "ledger.jsonl"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for _, r := range records {
+		if err := l.Append(r); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	return l
+}
+
+func TestSpentWindows(t *testing.T) {
+	from := common.HexToAddress(testFrom)
+	other := ethSend(9, "500")
+	other.FromAddress = testOther
+	otherChain := ethSend(10, "700")
+	otherChain.ChainID = 10
+
+	l := openTemp(t,
+		record(ethSend(1, "100"), "1", 30*time.Minute),
+		record(ethSend(2, "200"), "2", 5*time.Hour),
+		record(ethSend(3, "400"), "3", 3*24*time.Hour),
+		record(other, "other", time.Minute),
+		record(otherChain, "chain", time.Minute),
+		// Same nonce signed again for more: counts once, at the larger amount.
+		record(ethSend(1, "150"), "1b", 20*time.Minute),
+	)
+
+	tests := []struct {
+		name   string
+		window time.Duration
+		want   int64
+	}{
+		{"1h", time.Hour, 150},
+		{"24h", 24 * time.Hour, 350},
+		{"7d", 7 * 24 * time.Hour, 750},
+		{"none", time.Minute, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := l.Spent(1, from, AssetETH, testNow.Add(-tt.window))
+			if got.Cmp(big.NewInt(tt.want)) != 0 {
+				t.Fatalf("Spent = %s, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// A record at exactly the window start counts.
+	if got := l.Spent(1, from, AssetETH, testNow.Add(-5*time.Hour)); got.Cmp(big.NewInt(350)) != 0 {
+		t.Errorf("Spent from the record's time = %s, want 350", got)
+	}
+}
+
+func TestSpentWith(t *testing.T) {
+	from := common.HexToAddress(testFrom)
+	l := openTemp(t,
+		record(ethSend(1, "100"), "1", time.Minute),
+		record(ethSend(2, "200"), "2", time.Minute),
+	)
+	since := testNow.Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		nonce  uint64
+		amount int64
+		want   int64
+	}{
+		{"new nonce adds", 3, 50, 350},
+		{"replacement for less adds nothing", 2, 50, 300},
+		{"replacement for more adds the difference", 2, 260, 360},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := l.SpentWith(1, from, AssetETH, since, tt.nonce, big.NewInt(tt.amount))
+			if got.Cmp(big.NewInt(tt.want)) != 0 {
+				t.Fatalf("SpentWith = %s, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckNonce(t *testing.T) {
+	signed := record(ethSend(7, "100"), "7", time.Hour)
+	l := openTemp(t, signed, record(ethSend(8, "100"), "8", time.Hour))
+
+	replace := func(in *intent.EthSendIntent, txHash string) *intent.EthSendIntent {
+		in.ReplacesTxHash = txHash
+		return in
+	}
+	otherNonce := ethSend(7, "100")
+	otherNonce.FromAddress = testOther
+
+	tests := []struct {
+		name        string
+		in          *intent.EthSendIntent
+		signingHash string
+		wantErr     string
+		wantWarning bool
+	}{
+		{"next nonce", ethSend(9, "1"), "0xnew", "", false},
+		{"same transaction again", ethSend(7, "100"), signed.SigningHash, "", false},
+		{"different transaction, same nonce", ethSend(7, "999"), "0xnew", "already signed for a different transaction", false},
+		{"replacement named", replace(ethSend(7, "0"), signed.TxHash), "0xcancel", "", false},
+		{"replacement, upper-case hash", replace(ethSend(7, "0"), strings.ToUpper(signed.TxHash)), "0xcancel", "", false},
+		{"replacement of another nonce", replace(ethSend(9, "0"), signed.TxHash), "0xnew", "was signed for chain 1", false},
+		{"replacement not in ledger", replace(ethSend(7, "0"), "0xunknown"), "0xnew", "is not in the ledger", false},
+		{"other sender", otherNonce, "0xnew", "", false},
+		{"nonce gap", ethSend(12, "1"), "0xnew", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := l.CheckNonce(tt.in, tt.signingHash)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("CheckNonce: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("CheckNonce error = %v, want %q", err, tt.wantErr)
+			}
+			if got := c.Warning() != ""; got != tt.wantWarning {
+				t.Fatalf("Warning() = %q, want a warning: %v", c.Warning(), tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	l := openTemp(t, record(ethSend(1, "100"), "1", time.Minute))
+	reopened, err := Open(l.Path())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if len(reopened.Records()) != 1 || reopened.Records()[0].TxHash != "0xtx-1" {
+		t.Fatalf("Records() = %+v, want the appended record", reopened.Records())
+	}
+	if info, err := os.Stat(l.Path()); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("ledger mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+
+	line, err := jsonLine(record(ethSend(2, "100"), "2", time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, content, wantErr string
+	}{
+		{"missing", "", ""},
+		{"incomplete last line", string(line[:len(line)-1]), "incomplete"},
+		{"unknown field", strings.Replace(string(line), `{"v":1`, `{"v":1,"extra":1`, 1), "unknown field"},
+		{"duplicate field", strings.Replace(string(line), `{"v":1`, `{"v":1,"v":1`, 1), "duplicate field"},
+		{"wrong version", strings.Replace(string(line), `{"v":1`, `{"v":2`, 1), "unsupported record version"},
+		{"negative amount", strings.Replace(string(line), `"amount":"100"`, `"amount":"-1"`, 1), "invalid amount"},
+		{"bad time", strings.Replace(string(line), `"time":"`, `"time":"x`, 1), "invalid time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ledger.jsonl")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := Open(path)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Open: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Open error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
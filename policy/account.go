@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"coldsign/intent"
+)
+
+// Account is the policy for one sending account, named by its BIP-44
+// index (the intent's from.index). Its settings replace the policy's for
+// intents from that account; anything it leaves unset is the policy's.
+type Account struct {
+	Index uint32
+	Role  string // e.g. "treasury"; shown in the review
+
+	// Destinations, when set, replaces the policy's destination mode.
+	Destinations string
+
+	// Chains replaces the policy's limits for the chains it lists. Only
+	// chains the policy allows may be listed.
+	Chains map[uint64]*ChainPolicy
+
+	// Rules are evaluated after the policy's own.
+	Rules []*Rule
+}
+
+// AccountDocument is the on-disk form of an Account:
+//
+//	"5": {
+//	  "role": "ops-float",
+//	  "chains": {"1": {"maxValueWei": "20 ETH", "limits": {"ETH": {"24h": "40 ETH"}}}},
+//	  "rules": [{"name": "ops-hours", "expr": "hour >= 8 && hour < 18"}]
+//	}
+//
+// Role is required. Each chain starts from the policy's limits for that
+// chain, or from its profile's if one is given, and any ChainDocument
+// field overrides one. Rule names are unique across the policy's rules
+// and the account's.
+type AccountDocument struct {
+	Role         string                   `json:"role"`
+	Destinations string                   `json:"destinations,omitempty"`
+	Chains       map[string]ChainDocument `json:"chains,omitempty"`
+	Rules        []RuleDocument           `json:"rules,omitempty"`
+}
+
+// account parses ad against p, whose chains must already be parsed. names
+// holds the rule names p uses.
+func (ad *AccountDocument) account(p *Policy, index uint32, path string, names map[string]bool) (*Account, error) {
+	if !intent.ValidLabel(ad.Role) {
+		return nil, fmt.Errorf("%s/role: must be 1 to 64 letters, digits or . _ : -", path)
+	}
+	a := &Account{Index: index, Role: ad.Role}
+
+	switch ad.Destinations {
+	case "", DestinationsDenylist, DestinationsAllowlist:
+		a.Destinations = ad.Destinations
+	default:
+		return nil, fmt.Errorf("%s/destinations: want %s or %s, got %q", path, DestinationsDenylist, DestinationsAllowlist, ad.Destinations)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(ad.Chains)) {
+		cd := ad.Chains[key]
+		path := path + "/chains/" + key
+		id, err := strconv.ParseUint(key, 10, 64)
+		if err != nil || strconv.FormatUint(id, 10) != key {
+			return nil, fmt.Errorf("%s: chain ID must be a decimal integer", path)
+		}
+		base := p.Chains[id]
+		if base == nil {
+			return nil, fmt.Errorf("%s: chain %d is not in /chains", path, id)
+		}
+		if cd.Profile != "" {
+			fresh, err := newChainPolicy(cd.Profile)
+			if err != nil {
+				return nil, fmt.Errorf("%s/profile: %w", path, err)
+			}
+			fresh.Name = base.Name
+			base = fresh
+		}
+		c, err := cd.apply(base, path)
+		if err != nil {
+			return nil, err
+		}
+		if a.Chains == nil {
+			a.Chains = make(map[uint64]*ChainPolicy)
+		}
+		a.Chains[id] = c
+	}
+
+	var err error
+	if a.Rules, err = parseRules(ad.Rules, path+"/rules", names); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// document returns a in file form. Chains are written in full, with their
+// profile, so they read back the same whatever the policy's chains are.
+func (a *Account) document() AccountDocument {
+	ad := AccountDocument{
+		Role:         a.Role,
+		Destinations: a.Destinations,
+		Rules:        ruleDocuments(a.Rules),
+	}
+	if len(a.Chains) > 0 {
+		ad.Chains = make(map[string]ChainDocument, len(a.Chains))
+	}
+	for id, c := range a.Chains {
+		ad.Chains[strconv.FormatUint(id, 10)] = chainDocument(c)
+	}
+	return ad
+}
+
+// ForAccount returns the policy in force for intents from the account at
+// index: p itself if it has no Account for the index, otherwise a copy of
+// p with the account's settings applied and Account set. The copy is for
+// evaluating intents only; its Hash is not the policy's. Calling
+// ForAccount on the copy returns it unchanged.
+func (p *Policy) ForAccount(index uint32) *Policy {
+	a := p.Accounts[index]
+	if a == nil || p.Account == a {
+		return p
+	}
+	eff := *p
+	eff.Account = a
+	if a.Destinations != "" {
+		eff.Destinations = a.Destinations
+	}
+	if len(a.Chains) > 0 {
+		eff.Chains = maps.Clone(p.Chains)
+		maps.Copy(eff.Chains, a.Chains)
+	}
+	eff.Rules = append(slices.Clip(p.Rules), a.Rules...)
+	return &eff
+}
@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"coldsign/intent"
+	"coldsign/ledger"
+)
+
+// Chain profiles. A chain's limits start from its profile and may be
+// overridden one by one in the policy file.
+const (
+	ProfileMainnet = "mainnet" // real value at stake: tight caps
+	ProfileTestnet = "testnet" // test funds: any kind and recipient, small amounts
+)
+
+// ChainPolicy holds the limits for one chain.
+type ChainPolicy struct {
+	Name    string // e.g. "Base"; shown in the review
+	Profile string // ProfileMainnet or ProfileTestnet
+
+	MaxFeePerGasWei         *big.Int
+	MaxPriorityFeePerGasWei *big.Int
+	MaxValueWei             *big.Int
+
+	// MaxGasLimit caps the gas limit of contract calls (ERC20_TRANSFER).
+	MaxGasLimit uint64
+
+	// Fee sanity checks, each off when nil (see feeChecks). The floors keep
+	// a transaction from being stuck; the caps bound the worst-case fee,
+	// gas limit times maxFeePerGas.
+	MinFeePerGasWei         *big.Int
+	MinPriorityFeePerGasWei *big.Int
+	MaxTotalFeeWei          *big.Int
+	MaxFeeToValuePercent    *big.Rat // worst-case fee as a percentage of an ETH_SEND value
+	MaxBaseFeeMultiple      *big.Rat // maxFeePerGas over the intent's baseFeeWei
+
+	// RequireBaseFee refuses intents without a baseFeeWei snapshot.
+	RequireBaseFee bool
+
+	// Kinds lists the intent kinds allowed on the chain.
+	Kinds map[string]bool
+
+	// Recipients, when non-nil, is the only set of allowed destinations.
+	Recipients map[common.Address]bool
+
+	// Limits caps what each sender may send in rolling windows, counted
+	// from the ledger. Sorted by asset, then window.
+	Limits []Limit
+}
+
+// Windows are the rolling windows a Limit may use, shortest first.
+var Windows = []string{"24h", "7d", "30d"}
+
+var windowDurations = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// Limit caps the amount of one asset a sender may send on a chain within
+// a rolling window.
+type Limit struct {
+	Asset  string   // ledger.AssetETH or a checksummed token address
+	Window string   // one of Windows
+	Max    *big.Int // wei, or token base units
+}
+
+// Duration returns the length of the limit's window.
+func (l Limit) Duration() time.Duration { return windowDurations[l.Window] }
+
+// LimitUsage is a limit with what the sender has already sent in its
+// window.
+type LimitUsage struct {
+	Limit
+	Spent *big.Int // in the window, before this transaction
+	After *big.Int // with this transaction
+}
+
+// FormatAmount formats x in the limit's asset: ETH for the native asset,
+// base units for a token.
+func (l Limit) FormatAmount(x *big.Int) string {
+	if l.Asset != ledger.AssetETH {
+		return x.String() + " base units"
+	}
+	return ethString(x)
+}
+
+// Exceeded reports whether this transaction would go over the limit.
+func (u LimitUsage) Exceeded() bool { return u.After.Cmp(u.Max) > 0 }
+
+func eth(n int64) *big.Int  { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e18)) }
+func gwei(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9)) }
+
+func allKinds() map[string]bool {
+	kinds := make(map[string]bool, len(intent.Kinds))
+	for _, k := range intent.Kinds {
+		kinds[k] = true
+	}
+	return kinds
+}
+
+// newChainPolicy returns the limits of a profile.
+func newChainPolicy(profile string) (*ChainPolicy, error) {
+	switch profile {
+	case ProfileMainnet:
+		return &ChainPolicy{
+			Profile:                 ProfileMainnet,
+			MaxFeePerGasWei:         gwei(200),
+			MaxPriorityFeePerGasWei: gwei(10),
+			MaxValueWei:             eth(1000),
+			MaxGasLimit:             100_000, // enough for a token transfer
+			Kinds:                   allKinds(),
+		}, nil
+	case ProfileTestnet:
+		return &ChainPolicy{
+			Profile:                 ProfileTestnet,
+			MaxFeePerGasWei:         gwei(1000), // testnet fees spike freely
+			MaxPriorityFeePerGasWei: gwei(100),
+			MaxValueWei:             eth(10),
+			MaxGasLimit:             1_000_000,
+			Kinds:                   allKinds(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown profile %q (want %s or %s)", profile, ProfileMainnet, ProfileTestnet)
+	}
+}
+
+// Label names the chain for messages and the review, e.g.
+// "8453 (Base, mainnet profile)".
+func (c *ChainPolicy) Label(chainID uint64) string {
+	if c.Name == "" {
+		return fmt.Sprintf("%d (%s profile)", chainID, c.Profile)
+	}
+	return fmt.Sprintf("%d (%s, %s profile)", chainID, c.Name, c.Profile)
+}
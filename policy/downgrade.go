@@ -0,0 +1,95 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"coldsign/helpers"
+	"coldsign/intent"
+)
+
+// acceptedState is the newest signed policy accepted on this machine:
+//
+//	{"v":1,"version":12,"hash":"<hex>","time":"2026-10-19T09:30:00Z"}
+type acceptedState struct {
+	V       int    `json:"v"`
+	Version uint64 `json:"version"`
+	Hash    string `json:"hash"`
+	Time    string `json:"time"` // RFC 3339, when first accepted
+}
+
+func loadAccepted(path string) (acceptedState, error) {
+	var st acceptedState
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return st, err
+	default:
+		if err := intent.DecodeStrict(b, &st); err != nil {
+			return st, fmt.Errorf("%s: %w", path, err)
+		}
+		if st.V != 1 {
+			return st, fmt.Errorf("%s: unsupported version %d", path, st.V)
+		}
+	}
+	return st, nil
+}
+
+// CheckDowngrade compares p with the newest signed policy accepted before,
+// recorded in the state file at path. Once any signed policy has been
+// accepted, it refuses the built-in defaults, an unsigned policy, a lower
+// version and a different policy under the same version. It does not
+// change the record; see RecordAccepted.
+func (p *Policy) CheckDowngrade(path string) error {
+	st, err := loadAccepted(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case st.Version == 0:
+	case p.Source == DefaultSource:
+		return fmt.Errorf("signed policy version %d was accepted on %s, but no policy file is present", st.Version, st.Time)
+	case p.Signers == nil:
+		return fmt.Errorf("signed policy version %d was accepted on %s; an unsigned policy is refused from then on", st.Version, st.Time)
+	case p.Version < st.Version:
+		return fmt.Errorf("policy version %d is older than version %d, accepted on %s (downgrade refused)", p.Version, st.Version, st.Time)
+	case p.Version == st.Version && p.Hash() != st.Hash:
+		return fmt.Errorf("policy version %d differs from the version %d accepted on %s (%s); a changed policy needs a new version",
+			p.Version, st.Version, st.Time, intent.ShortHash(st.Hash))
+	}
+	return nil
+}
+
+// RecordAccepted records p in the state file at path as the newest signed
+// policy accepted, if it is signed and newer than the one recorded. Call
+// it after CheckDowngrade.
+func (p *Policy) RecordAccepted(path string, now time.Time) error {
+	st, err := loadAccepted(path)
+	if err != nil {
+		return err
+	}
+	if p.Signers == nil || p.Version <= st.Version {
+		return nil
+	}
+	st = acceptedState{V: 1, Version: p.Version, Hash: p.Hash(), Time: now.UTC().Format(time.RFC3339)}
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := helpers.WriteNewFile(tmp, append(b, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
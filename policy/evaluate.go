@@ -0,0 +1,393 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"coldsign/addrbook"
+	"coldsign/helpers"
+	"coldsign/intent"
+	"coldsign/ledger"
+)
+
+// Check results.
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn" // allowed, with a warning the operator must see
+	CheckFail = "fail" // the intent is refused
+)
+
+// Check is the outcome of one policy check: what the intent has, what the
+// policy allows and the verdict.
+type Check struct {
+	Name    string // e.g. "maxFeePerGas", "limit 24h ETH", "rule weekend"
+	Actual  string // what the intent has, e.g. "250 gwei"
+	Limit   string // what the policy allows, e.g. "at most 200 gwei"
+	Result  string // CheckPass, CheckWarn or CheckFail
+	Message string // why the check warned or failed; empty on a pass
+}
+
+// result sets the verdict from an Enforce* style outcome.
+func (c Check) result(warning string, err error) Check {
+	switch {
+	case err != nil:
+		c.Result, c.Message = CheckFail, err.Error()
+	case warning != "":
+		c.Result, c.Message = CheckWarn, warning
+	default:
+		c.Result = CheckPass
+	}
+	return c
+}
+
+// Evaluation is the outcome of every policy check on one intent, in the
+// order they are reported. Unlike the Enforce methods it does not stop at
+// the first failure.
+type Evaluation struct {
+	Checks []Check
+	Usage  []LimitUsage // rolling limits for the intent's asset
+	Rules  []RuleResult
+
+	// Account is the sender's account policy, or nil if the policy's
+	// defaults apply.
+	Account *Account
+}
+
+// OK reports whether no check failed.
+func (e *Evaluation) OK() bool { return e.Err() == nil }
+
+// Failed returns the checks that failed.
+func (e *Evaluation) Failed() []Check {
+	var failed []Check
+	for _, c := range e.Checks {
+		if c.Result == CheckFail {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Warnings returns the message of each check that warned.
+func (e *Evaluation) Warnings() []string {
+	var warnings []string
+	for _, c := range e.Checks {
+		if c.Result == CheckWarn {
+			warnings = append(warnings, c.Message)
+		}
+	}
+	return warnings
+}
+
+// Err returns the first failed check as an error, noting how many others
+// failed, or nil if none did.
+func (e *Evaluation) Err() error {
+	failed := e.Failed()
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(failed[0].Message)
+	default:
+		return fmt.Errorf("%s (and %d more failed checks)", failed[0].Message, len(failed)-1)
+	}
+}
+
+// Evaluate runs every policy check on the intent: origin, expiry,
+// destination, the chain's limits, rolling limits and rules.
+func (p *Policy) Evaluate(in *intent.EthSendIntent, f Facts) *Evaluation {
+	p = p.ForAccount(in.From.Index)
+	e := &Evaluation{Account: p.Account}
+	e.Checks = append(e.Checks,
+		p.checkOrigin(f.Auth),
+		p.checkExpiry(in, f),
+		p.checkDestination(in.To, f.Dest),
+		p.checkChain(in.ChainID),
+	)
+	if c := p.Chain(in.ChainID); c != nil {
+		e.Checks = append(e.Checks, c.checks(in, f.Auth)...)
+	}
+
+	usage, err := p.Usage(in, f.Ledger, f.Now)
+	if err != nil {
+		e.Checks = append(e.Checks, Check{Name: "limits", Actual: "no ledger", Limit: "counted from the ledger"}.result("", err))
+	}
+	e.Usage = usage
+	for _, u := range usage {
+		e.Checks = append(e.Checks, u.check())
+	}
+
+	e.Rules = p.EvaluateRules(in, f)
+	for _, r := range e.Rules {
+		e.Checks = append(e.Checks, r.check())
+	}
+	return e
+}
+
+func (p *Policy) checkOrigin(res intent.AuthResult) Check {
+	c := Check{Name: "origin", Actual: res.String(), Limit: "signed by a trusted coordinator"}
+	if p.UnauthenticatedIntents == IntentAuthWarn {
+		c.Limit += ", else warn"
+	}
+	return c.result(p.EnforceIntentAuth(res))
+}
+
+func (p *Policy) checkExpiry(in *intent.EthSendIntent, f Facts) Check {
+	c := Check{Name: "expiry", Actual: in.ValidUntil, Limit: "not expired by the local clock"}
+	if c.Actual == "" {
+		c.Actual = "no validUntil"
+	}
+	if p.Clock == ClockUntrusted {
+		c.Limit = "judged by the operator (local clock untrusted)"
+	}
+	if p.RequireExpiry {
+		c.Limit = "validUntil required, " + c.Limit
+	}
+	return c.result(p.EnforceExpiry(in, f.Now))
+}
+
+func (p *Policy) checkDestination(to string, dest *addrbook.Entry) Check {
+	c := Check{Name: "destination", Actual: "not in the address book", Limit: "not denylisted"}
+	if dest != nil {
+		c.Actual = dest.String()
+	}
+	if p.Destinations == DestinationsAllowlist {
+		c.Limit = "in the address book, not denylisted"
+	}
+	return c.result("", p.EnforceDestination(to, dest))
+}
+
+func (p *Policy) checkChain(chainID uint64) Check {
+	ids := slices.Sorted(maps.Keys(p.Chains))
+	allowed := make([]string, len(ids))
+	for i, id := range ids {
+		allowed[i] = strconv.FormatUint(id, 10)
+	}
+	c := Check{Name: "chain", Actual: strconv.FormatUint(chainID, 10), Limit: "one of " + strings.Join(allowed, ", ")}
+	var err error
+	if p.Chain(chainID) == nil {
+		err = fmt.Errorf("chainId %d not allowed by policy", chainID)
+	}
+	return c.result("", err)
+}
+
+// checks compares the intent with the chain's limits. auth is the intent's
+// origin, which decides whether its base fee snapshot can be trusted.
+func (c *ChainPolicy) checks(in *intent.EthSendIntent, auth intent.AuthResult) []Check {
+	var checks []Check
+
+	kinds := make([]string, 0, len(c.Kinds))
+	for k, ok := range c.Kinds {
+		if ok {
+			kinds = append(kinds, k)
+		}
+	}
+	slices.Sort(kinds)
+	kind := Check{Name: "kind", Actual: in.Kind, Limit: strings.Join(kinds, " or ")}
+	var err error
+	if !c.Kinds[in.Kind] {
+		err = fmt.Errorf("%s is not allowed on chain %d", in.Kind, in.ChainID)
+	}
+	checks = append(checks, kind.result("", err))
+
+	if c.Recipients != nil {
+		to := common.HexToAddress(in.To)
+		rc := Check{Name: "recipient", Actual: to.Hex(), Limit: fmt.Sprintf("chain allowlist (%d addresses)", len(c.Recipients))}
+		err = nil
+		if !c.Recipients[to] {
+			err = fmt.Errorf("recipient %s is not on the chain %d allowlist", in.To, in.ChainID)
+		}
+		checks = append(checks, rc.result("", err))
+	}
+
+	maxFee, _ := parseWei(in.MaxFeePerGasWei)
+	maxPrio, _ := parseWei(in.MaxPriorityFeePerGasWei)
+	value, _ := parseWei(in.ValueWei)
+	checks = append(checks,
+		atMost("maxFeePerGas", maxFee, c.MaxFeePerGasWei, gweiString),
+		atMost("maxPriorityFeePerGas", maxPrio, c.MaxPriorityFeePerGasWei, gweiString),
+		atMost("value", value, c.MaxValueWei, ethString),
+		atMost("gasLimit", new(big.Int).SetUint64(in.Gas()), new(big.Int).SetUint64(c.MaxGasLimit), (*big.Int).String),
+	)
+	return append(checks, c.feeChecks(in, auth)...)
+}
+
+// feeChecks judges the fees beyond their caps. The tip must fit within
+// maxFeePerGas; the optional floors keep the transaction from being stuck;
+// the worst-case fee may be capped outright and as a share of the value;
+// and maxFeePerGas is compared with the intent's base fee snapshot, which
+// only counts when a trusted coordinator signed it.
+func (c *ChainPolicy) feeChecks(in *intent.EthSendIntent, auth intent.AuthResult) []Check {
+	maxFee, _ := parseWei(in.MaxFeePerGasWei)
+	tip, _ := parseWei(in.MaxPriorityFeePerGasWei)
+	value, _ := parseWei(in.ValueWei)
+	total := new(big.Int).Mul(new(big.Int).SetUint64(in.Gas()), maxFee)
+
+	order := Check{
+		Name:   "tip vs maxFee",
+		Actual: fmt.Sprintf("tip %s, maxFeePerGas %s", gweiString(tip), gweiString(maxFee)),
+		Limit:  "tip at most maxFeePerGas",
+	}
+	var err error
+	if tip.Cmp(maxFee) > 0 {
+		err = fmt.Errorf("maxPriorityFeePerGas %s is above maxFeePerGas %s; the tip can never be paid in full",
+			gweiString(tip), gweiString(maxFee))
+	}
+	checks := []Check{order.result("", err)}
+
+	if c.MinFeePerGasWei != nil {
+		checks = append(checks, atLeast("maxFeePerGas", maxFee, c.MinFeePerGasWei))
+	}
+	if c.MinPriorityFeePerGasWei != nil {
+		checks = append(checks, atLeast("maxPriorityFeePerGas", tip, c.MinPriorityFeePerGasWei))
+	}
+	if c.MaxTotalFeeWei != nil {
+		checks = append(checks, atMost("worst-case fee", total, c.MaxTotalFeeWei, ethString))
+	}
+
+	if c.MaxFeeToValuePercent != nil && in.Kind == intent.KindEthSend && value.Sign() > 0 {
+		percent := new(big.Rat).SetFrac(new(big.Int).Mul(total, big.NewInt(100)), value)
+		ratio := Check{
+			Name:   "fee vs value",
+			Actual: fmt.Sprintf("%s%% (%s worst-case fee on %s)", percent.FloatString(2), ethString(total), ethString(value)),
+			Limit:  "at most " + formatDecimal(c.MaxFeeToValuePercent) + "%",
+		}
+		err = nil
+		if percent.Cmp(c.MaxFeeToValuePercent) > 0 {
+			err = fmt.Errorf("worst-case fee %s is %s%% of the value %s; policy allows at most %s%%",
+				ethString(total), percent.FloatString(2), ethString(value), formatDecimal(c.MaxFeeToValuePercent))
+		}
+		checks = append(checks, ratio.result("", err))
+	}
+
+	switch {
+	case in.BaseFeeWei != "" && auth.Authenticated():
+		checks = append(checks, c.baseFeeCheck(maxFee, in.BaseFeeWei))
+	case in.BaseFeeWei != "":
+		checks = append(checks, c.unsignedBaseFeeCheck(maxFee, in.BaseFeeWei))
+	case c.RequireBaseFee:
+		checks = append(checks, Check{Name: "base fee", Actual: "no baseFeeWei", Limit: "baseFeeWei required"}.
+			result("", errors.New("intent has no baseFeeWei; policy requires a base fee snapshot")))
+	}
+	return checks
+}
+
+// baseFeeCheck compares maxFeePerGas with the intent's base fee snapshot:
+// below it, the transaction cannot be mined until the base fee falls.
+func (c *ChainPolicy) baseFeeCheck(maxFee *big.Int, baseFeeWei string) Check {
+	baseFee, _ := parseWei(baseFeeWei)
+	ch := Check{Name: "base fee", Actual: fmt.Sprintf("maxFeePerGas %s, base fee %s", gweiString(maxFee), gweiString(baseFee)), Limit: "maxFeePerGas at least the base fee"}
+	var multiple *big.Rat
+	if baseFee.Sign() > 0 {
+		multiple = new(big.Rat).SetFrac(maxFee, baseFee)
+		ch.Actual += fmt.Sprintf(" (%sx)", multiple.FloatString(2))
+	}
+	if c.MaxBaseFeeMultiple != nil {
+		ch.Limit = "maxFeePerGas from 1x to " + formatDecimal(c.MaxBaseFeeMultiple) + "x the base fee"
+	}
+
+	var err error
+	switch {
+	case maxFee.Cmp(baseFee) < 0:
+		err = fmt.Errorf("maxFeePerGas %s is below the intent's base fee of %s; the transaction cannot be mined until the base fee falls",
+			gweiString(maxFee), gweiString(baseFee))
+	case c.MaxBaseFeeMultiple != nil && (multiple == nil || multiple.Cmp(c.MaxBaseFeeMultiple) > 0):
+		err = fmt.Errorf("maxFeePerGas %s is over %sx the intent's base fee of %s",
+			gweiString(maxFee), formatDecimal(c.MaxBaseFeeMultiple), gweiString(baseFee))
+	}
+	return ch.result("", err)
+}
+
+// unsignedBaseFeeCheck judges a base fee snapshot that no trusted
+// coordinator signed. Anyone on the way could have chosen it, so a policy
+// that relies on it (requireBaseFee, maxBaseFeeMultiple) refuses the
+// intent, and otherwise the comparison only warns.
+func (c *ChainPolicy) unsignedBaseFeeCheck(maxFee *big.Int, baseFeeWei string) Check {
+	ch := c.baseFeeCheck(maxFee, baseFeeWei)
+	ch.Actual += ", unsigned"
+	if c.RequireBaseFee || c.MaxBaseFeeMultiple != nil {
+		ch.Limit = "a base fee signed by a trusted coordinator"
+		return ch.result("", errors.New("baseFeeWei is not signed by a trusted coordinator; policy requires a signed base fee snapshot"))
+	}
+	if ch.Result == CheckFail {
+		return ch.result("unsigned base fee snapshot: "+ch.Message, nil)
+	}
+	return ch.result("baseFeeWei is not signed by a trusted coordinator; it is shown, not relied on", nil)
+}
+
+// atMost checks that x does not exceed max; format renders both.
+func atMost(name string, x, max *big.Int, format func(*big.Int) string) Check {
+	c := Check{Name: name, Actual: format(x), Limit: "at most " + format(max)}
+	var err error
+	if x.Cmp(max) > 0 {
+		err = fmt.Errorf("%s %s exceeds the policy limit of %s", name, format(x), format(max))
+	}
+	return c.result("", err)
+}
+
+// atLeast checks that a fee is not below its floor.
+func atLeast(name string, x, min *big.Int) Check {
+	c := Check{Name: name + " floor", Actual: gweiString(x), Limit: "at least " + gweiString(min)}
+	var err error
+	if x.Cmp(min) < 0 {
+		err = fmt.Errorf("%s %s is below the policy floor of %s; the transaction may never be mined", name, gweiString(x), gweiString(min))
+	}
+	return c.result("", err)
+}
+
+func (u LimitUsage) check() Check {
+	asset := u.Asset
+	if asset != ledger.AssetETH {
+		asset = helpers.ShortAddr(asset)
+	}
+	c := Check{
+		Name:   "limit " + u.Window + " " + asset,
+		Actual: fmt.Sprintf("%s with this tx (%s before)", u.FormatAmount(u.After), u.FormatAmount(u.Spent)),
+		Limit:  "at most " + u.FormatAmount(u.Max),
+	}
+	var err error
+	if u.Exceeded() {
+		err = fmt.Errorf("%s limit for %s exceeded: %s sent, %s with this transaction, limit %s",
+			u.Window, u.Asset, u.FormatAmount(u.Spent), u.FormatAmount(u.After), u.FormatAmount(u.Max))
+	}
+	return c.result("", err)
+}
+
+func (r RuleResult) check() Check {
+	c := Check{Name: "rule " + r.Rule.Name, Actual: strconv.FormatBool(r.Passed), Limit: r.Rule.Expr.String()}
+	if r.Rule.Action == RuleWarn {
+		c.Limit += ", else warn"
+	}
+	switch {
+	case r.Err != nil:
+		c.Actual = "error: " + r.Err.Error()
+		return c.result("", fmt.Errorf("rule %q could not be evaluated: %w", r.Rule.Name, r.Err))
+	case r.Passed:
+		return c.result("", nil)
+	case r.Rule.Action == RuleWarn:
+		return c.result(fmt.Sprintf("rule %q failed: %s", r.Rule.Name, r.Rule.Expr), nil)
+	default:
+		return c.result("", fmt.Errorf("rule %q failed: %s", r.Rule.Name, r.Rule.Expr))
+	}
+}
+
+// ethString and gweiString format wei exactly, without trailing zeros.
+func ethString(x *big.Int) string  { return unitString(x, helpers.Eth, 18) + " ETH" }
+func gweiString(x *big.Int) string { return unitString(x, helpers.Gwei, 9) + " gwei" }
+
+func unitString(x *big.Int, unit helpers.Unit, decimals int) string {
+	s, err := helpers.FormatWeiString(x.String(), unit, decimals)
+	if err != nil {
+		return x.String() + " wei"
+	}
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
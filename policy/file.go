@@ -0,0 +1,648 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"coldsign/helpers"
+	"coldsign/intent"
+	"coldsign/ledger"
+	"coldsign/rules"
+)
+
+// DocumentVersion is the current policy file format version. Version 1
+// files (one set of limits for every allowed chain) are still read.
+const DocumentVersion = 2
+
+// DefaultSource is the Source of the built-in policy.
+const DefaultSource = "built-in defaults"
+
+// Document is the on-disk form of a Policy:
+//
+//	{
+//	  "v": 2,
+//	  "version": 12,
+//	  "chains": {
+//	    "1":        {"name": "Ethereum", "profile": "mainnet",
+//	                 "limits": {"ETH": {"24h": "50 ETH", "30d": "500 ETH"}}},
+//	    "8453":     {"name": "Base", "profile": "mainnet", "maxFeePerGasWei": "5 gwei"},
+//	    "11155111": {"name": "Sepolia", "profile": "testnet"}
+//	  },
+//	  "unauthenticatedIntents": "warn",
+//	  "destinations": "denylist",
+//	  "clock": "trusted",
+//	  "requireExpiry": false,
+//	  "rules": [
+//	    {"name": "tip-under-20pct", "expr": "tip * 5 < maxFee", "action": "warn"}
+//	  ],
+//	  "accounts": {
+//	    "0": {"role": "treasury", "destinations": "allowlist"}
+//	  }
+//	}
+//
+// V is the file format; version numbers the policy's own revisions and is
+// required for a signed policy (see Policy.Verify). Chains are keyed by
+// decimal chain ID; no other chain is allowed. Each starts from its
+// profile's limits, and any ChainDocument field overrides one. The last
+// six fields are optional; rules are described by RuleDocument and
+// accounts, keyed by decimal from.index, by AccountDocument.
+type Document struct {
+	V                      int                        `json:"v"`
+	Version                uint64                     `json:"version,omitempty"`
+	Chains                 map[string]ChainDocument   `json:"chains"`
+	UnauthenticatedIntents string                     `json:"unauthenticatedIntents,omitempty"`
+	Destinations           string                     `json:"destinations,omitempty"`
+	Clock                  string                     `json:"clock,omitempty"`
+	RequireExpiry          bool                       `json:"requireExpiry,omitempty"`
+	Rules                  []RuleDocument             `json:"rules,omitempty"`
+	Accounts               map[string]AccountDocument `json:"accounts,omitempty"`
+}
+
+// RuleDocument is the on-disk form of a Rule. Expr is written in the
+// rules expression language over RuleVars and must be true for an intent
+// to pass; Action (default "refuse") applies when it is false. Names are
+// unique labels.
+type RuleDocument struct {
+	Name   string `json:"name"`
+	Expr   string `json:"expr"`
+	Action string `json:"action,omitempty"`
+}
+
+// ChainDocument is the on-disk form of a ChainPolicy. Profile is required.
+// Amounts are wei, as a decimal string or with a unit ("5 gwei"). Kinds
+// replaces the profile's allowed intent kinds; Recipients, when given, is
+// the only set of allowed destinations.
+//
+// The fee sanity fields are optional and off unless set: floors on
+// maxFeePerGas and maxPriorityFeePerGas, a cap on the worst-case fee (gas
+// limit times maxFeePerGas), that fee's largest share of an ETH_SEND value
+// in percent ("2.5"), and the largest multiple of the intent's baseFeeWei
+// that maxFeePerGas may be ("3"). RequireBaseFee refuses intents without
+// baseFeeWei.
+//
+// Limits maps an asset ("ETH", or a token contract address) to rolling
+// windows ("24h", "7d", "30d") and the most each sender may send in one.
+// Token limits are in base units, as a decimal string.
+type ChainDocument struct {
+	Name                    string   `json:"name,omitempty"`
+	Profile                 string   `json:"profile"`
+	MaxFeePerGasWei         string   `json:"maxFeePerGasWei,omitempty"`
+	MaxPriorityFeePerGasWei string   `json:"maxPriorityFeePerGasWei,omitempty"`
+	MaxValueWei             string   `json:"maxValueWei,omitempty"`
+	MaxGasLimit             uint64   `json:"maxGasLimit,omitempty"`
+	Kinds                   []string `json:"kinds,omitempty"`
+	Recipients              []string `json:"recipients,omitempty"`
+
+	MinFeePerGasWei         string `json:"minFeePerGasWei,omitempty"`
+	MinPriorityFeePerGasWei string `json:"minPriorityFeePerGasWei,omitempty"`
+	MaxTotalFeeWei          string `json:"maxTotalFeeWei,omitempty"`
+	MaxFeeToValuePercent    string `json:"maxFeeToValuePercent,omitempty"`
+	MaxBaseFeeMultiple      string `json:"maxBaseFeeMultiple,omitempty"`
+	RequireBaseFee          bool   `json:"requireBaseFee,omitempty"`
+
+	Limits map[string]map[string]string `json:"limits,omitempty"`
+}
+
+// documentV1 is the version 1 format: one set of limits, all required, for
+// every allowed chain.
+type documentV1 struct {
+	V                       int      `json:"v"`
+	AllowedChainIDs         []uint64 `json:"allowedChainIds"`
+	MaxFeePerGasWei         string   `json:"maxFeePerGasWei"`
+	MaxPriorityFeePerGasWei string   `json:"maxPriorityFeePerGasWei"`
+	MaxValueWei             string   `json:"maxValueWei"`
+	MaxGasLimit             uint64   `json:"maxGasLimit"`
+	UnauthenticatedIntents  string   `json:"unauthenticatedIntents,omitempty"`
+	Clock                   string   `json:"clock,omitempty"`
+	RequireExpiry           bool     `json:"requireExpiry,omitempty"`
+}
+
+// Load reads a policy file.
+func Load(path string) (*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p.Source = path
+	return p, nil
+}
+
+// Parse decodes and validates a policy document of any supported version.
+// It is as strict as intent parsing (see intent.DecodeStrict): duplicate or
+// unknown fields, nulls and trailing data are refused.
+func Parse(b []byte) (*Policy, error) {
+	if err := intent.CheckStrictJSON(b); err != nil {
+		return nil, err
+	}
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(b, &present); err != nil {
+		return nil, err
+	}
+	var version int
+	if err := json.Unmarshal(present["v"], &version); err != nil || present["v"] == nil {
+		return nil, fmt.Errorf("/v: is required")
+	}
+
+	switch version {
+	case 1:
+		if err := requireFields(present, "allowedChainIds", "maxFeePerGasWei", "maxPriorityFeePerGasWei", "maxValueWei", "maxGasLimit"); err != nil {
+			return nil, err
+		}
+		var doc documentV1
+		if err := intent.DecodeStrict(b, &doc); err != nil {
+			return nil, err
+		}
+		doc2, err := doc.upgrade()
+		if err != nil {
+			return nil, err
+		}
+		return doc2.policy()
+	case DocumentVersion:
+		if err := requireFields(present, "chains"); err != nil {
+			return nil, err
+		}
+		var doc Document
+		if err := intent.DecodeStrict(b, &doc); err != nil {
+			return nil, err
+		}
+		return doc.policy()
+	default:
+		return nil, fmt.Errorf("/v: unsupported policy version %d (want %d)", version, DocumentVersion)
+	}
+}
+
+func requireFields(present map[string]json.RawMessage, names ...string) error {
+	for _, name := range names {
+		if _, ok := present[name]; !ok {
+			return fmt.Errorf("/%s: is required", name)
+		}
+	}
+	return nil
+}
+
+// upgrade converts a version 1 policy: each allowed chain gets the mainnet
+// profile with the file's limits.
+func (d *documentV1) upgrade() (*Document, error) {
+	if len(d.AllowedChainIDs) == 0 {
+		return nil, fmt.Errorf("/allowedChainIds: must list at least one chain")
+	}
+	doc := &Document{
+		V:                      DocumentVersion,
+		Chains:                 make(map[string]ChainDocument),
+		UnauthenticatedIntents: d.UnauthenticatedIntents,
+		Clock:                  d.Clock,
+		RequireExpiry:          d.RequireExpiry,
+	}
+	for _, id := range d.AllowedChainIDs {
+		key := strconv.FormatUint(id, 10)
+		if _, dup := doc.Chains[key]; dup {
+			return nil, fmt.Errorf("/allowedChainIds: chain %d listed twice", id)
+		}
+		doc.Chains[key] = ChainDocument{
+			Profile:                 ProfileMainnet,
+			MaxFeePerGasWei:         d.MaxFeePerGasWei,
+			MaxPriorityFeePerGasWei: d.MaxPriorityFeePerGasWei,
+			MaxValueWei:             d.MaxValueWei,
+			MaxGasLimit:             d.MaxGasLimit,
+		}
+	}
+	return doc, nil
+}
+
+func (d *Document) policy() (*Policy, error) {
+	p := &Policy{
+		Version:                d.Version,
+		Chains:                 make(map[uint64]*ChainPolicy),
+		UnauthenticatedIntents: IntentAuthWarn,
+		Destinations:           DestinationsDenylist,
+		Clock:                  ClockTrusted,
+		RequireExpiry:          d.RequireExpiry,
+	}
+
+	if len(d.Chains) == 0 {
+		return nil, fmt.Errorf("/chains: must list at least one chain")
+	}
+	for _, key := range slices.Sorted(maps.Keys(d.Chains)) {
+		cd := d.Chains[key]
+		path := "/chains/" + key
+		id, err := strconv.ParseUint(key, 10, 64)
+		if err != nil || strconv.FormatUint(id, 10) != key {
+			return nil, fmt.Errorf("%s: chain ID must be a decimal integer", path)
+		}
+		c, err := cd.chainPolicy(path)
+		if err != nil {
+			return nil, err
+		}
+		p.Chains[id] = c
+	}
+
+	names := make(map[string]bool)
+	var err error
+	if p.Rules, err = parseRules(d.Rules, "/rules", names); err != nil {
+		return nil, err
+	}
+
+	switch d.UnauthenticatedIntents {
+	case "":
+	case IntentAuthRefuse, IntentAuthWarn:
+		p.UnauthenticatedIntents = d.UnauthenticatedIntents
+	default:
+		return nil, fmt.Errorf("/unauthenticatedIntents: want %s or %s, got %q", IntentAuthRefuse, IntentAuthWarn, d.UnauthenticatedIntents)
+	}
+
+	switch d.Destinations {
+	case "":
+	case DestinationsDenylist, DestinationsAllowlist:
+		p.Destinations = d.Destinations
+	default:
+		return nil, fmt.Errorf("/destinations: want %s or %s, got %q", DestinationsDenylist, DestinationsAllowlist, d.Destinations)
+	}
+
+	switch d.Clock {
+	case "":
+	case ClockTrusted, ClockUntrusted:
+		p.Clock = d.Clock
+	default:
+		return nil, fmt.Errorf("/clock: want %s or %s, got %q", ClockTrusted, ClockUntrusted, d.Clock)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(d.Accounts)) {
+		path := "/accounts/" + key
+		index, err := strconv.ParseUint(key, 10, 32)
+		if err != nil || strconv.FormatUint(index, 10) != key {
+			return nil, fmt.Errorf("%s: account must be a decimal BIP-44 index", path)
+		}
+		ad := d.Accounts[key]
+		a, err := ad.account(p, uint32(index), path, maps.Clone(names))
+		if err != nil {
+			return nil, err
+		}
+		if p.Accounts == nil {
+			p.Accounts = make(map[uint32]*Account)
+		}
+		p.Accounts[a.Index] = a
+	}
+
+	return p, nil
+}
+
+// parseRules compiles rule documents. names holds the rule names already
+// taken; the new ones are added.
+func parseRules(docs []RuleDocument, path string, names map[string]bool) ([]*Rule, error) {
+	var parsed []*Rule
+	for i, rd := range docs {
+		path := fmt.Sprintf("%s/%d", path, i)
+		if !intent.ValidLabel(rd.Name) {
+			return nil, fmt.Errorf("%s/name: must be 1 to 64 letters, digits or . _ : -", path)
+		}
+		if names[rd.Name] {
+			return nil, fmt.Errorf("%s/name: %q is used by another rule", path, rd.Name)
+		}
+		names[rd.Name] = true
+		expr, err := rules.Compile(rd.Expr, RuleVars)
+		if err != nil {
+			return nil, fmt.Errorf("%s/expr: %w", path, err)
+		}
+		rule := &Rule{Name: rd.Name, Expr: expr, Action: RuleRefuse}
+		switch rd.Action {
+		case "":
+		case RuleRefuse, RuleWarn:
+			rule.Action = rd.Action
+		default:
+			return nil, fmt.Errorf("%s/action: want %s or %s, got %q", path, RuleRefuse, RuleWarn, rd.Action)
+		}
+		parsed = append(parsed, rule)
+	}
+	return parsed, nil
+}
+
+func (cd *ChainDocument) chainPolicy(path string) (*ChainPolicy, error) {
+	c, err := newChainPolicy(cd.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("%s/profile: %w", path, err)
+	}
+	return cd.apply(c, path)
+}
+
+// apply returns a copy of c with the document's fields set over it. The
+// fields of c are replaced, never modified, so c may be shared.
+func (cd *ChainDocument) apply(base *ChainPolicy, path string) (*ChainPolicy, error) {
+	c := *base
+	if cd.Name != "" {
+		c.Name = cd.Name
+	}
+
+	for _, a := range []struct {
+		field string
+		s     string
+		dst   **big.Int
+	}{
+		{"maxFeePerGasWei", cd.MaxFeePerGasWei, &c.MaxFeePerGasWei},
+		{"maxPriorityFeePerGasWei", cd.MaxPriorityFeePerGasWei, &c.MaxPriorityFeePerGasWei},
+		{"maxValueWei", cd.MaxValueWei, &c.MaxValueWei},
+		{"minFeePerGasWei", cd.MinFeePerGasWei, &c.MinFeePerGasWei},
+		{"minPriorityFeePerGasWei", cd.MinPriorityFeePerGasWei, &c.MinPriorityFeePerGasWei},
+		{"maxTotalFeeWei", cd.MaxTotalFeeWei, &c.MaxTotalFeeWei},
+	} {
+		if a.s == "" {
+			continue
+		}
+		wei, err := parseAmount(a.s)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", path, a.field, err)
+		}
+		*a.dst = wei
+	}
+	if cd.MaxGasLimit != 0 {
+		c.MaxGasLimit = cd.MaxGasLimit
+	}
+	for _, a := range []struct {
+		field string
+		s     string
+		dst   **big.Rat
+	}{
+		{"maxFeeToValuePercent", cd.MaxFeeToValuePercent, &c.MaxFeeToValuePercent},
+		{"maxBaseFeeMultiple", cd.MaxBaseFeeMultiple, &c.MaxBaseFeeMultiple},
+	} {
+		if a.s == "" {
+			continue
+		}
+		x, err := parseDecimal(a.s)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", path, a.field, err)
+		}
+		*a.dst = x
+	}
+	if c.MinFeePerGasWei != nil && c.MinFeePerGasWei.Cmp(c.MaxFeePerGasWei) > 0 {
+		return nil, fmt.Errorf("%s/minFeePerGasWei: above maxFeePerGasWei; no fee could pass", path)
+	}
+	if c.MinPriorityFeePerGasWei != nil && c.MinPriorityFeePerGasWei.Cmp(c.MaxPriorityFeePerGasWei) > 0 {
+		return nil, fmt.Errorf("%s/minPriorityFeePerGasWei: above maxPriorityFeePerGasWei; no fee could pass", path)
+	}
+	if cd.RequireBaseFee {
+		c.RequireBaseFee = true
+	}
+
+	if cd.Kinds != nil {
+		if len(cd.Kinds) == 0 {
+			return nil, fmt.Errorf("%s/kinds: must list at least one kind (omit for the profile default)", path)
+		}
+		known := allKinds()
+		c.Kinds = make(map[string]bool)
+		for i, k := range cd.Kinds {
+			if !known[k] {
+				return nil, fmt.Errorf("%s/kinds/%d: unknown intent kind %q", path, i, k)
+			}
+			c.Kinds[k] = true
+		}
+	}
+
+	if cd.Recipients != nil {
+		if len(cd.Recipients) == 0 {
+			return nil, fmt.Errorf("%s/recipients: must list at least one address (omit to allow any)", path)
+		}
+		c.Recipients = make(map[common.Address]bool)
+		for i, r := range cd.Recipients {
+			addr, err := parseAddress(r)
+			if err != nil {
+				return nil, fmt.Errorf("%s/recipients/%d: %w", path, i, err)
+			}
+			c.Recipients[addr] = true
+		}
+	}
+
+	if cd.Limits != nil {
+		limits, err := parseLimits(cd.Limits, path+"/limits")
+		if err != nil {
+			return nil, err
+		}
+		c.Limits = limits
+	}
+
+	return &c, nil
+}
+
+func parseLimits(doc map[string]map[string]string, path string) ([]Limit, error) {
+	var limits []Limit
+	assets := make(map[string]string) // normalized asset -> key, to catch case variants
+	for _, key := range slices.Sorted(maps.Keys(doc)) {
+		windows := doc[key]
+		p := path + "/" + key
+		asset := ledger.AssetETH
+		if key != ledger.AssetETH {
+			addr, err := parseAddress(key)
+			if err != nil {
+				return nil, fmt.Errorf("%s: want %s or a token address: %w", p, ledger.AssetETH, err)
+			}
+			asset = addr.Hex()
+		}
+		if other, dup := assets[asset]; dup {
+			return nil, fmt.Errorf("%s: same asset as %s", p, other)
+		}
+		assets[asset] = key
+		if len(windows) == 0 {
+			return nil, fmt.Errorf("%s: must set at least one window", p)
+		}
+
+		for _, w := range Windows {
+			s, ok := windows[w]
+			if !ok {
+				continue
+			}
+			var max *big.Int
+			var err error
+			if asset == ledger.AssetETH {
+				max, err = parseAmount(s)
+			} else {
+				max, err = parseBaseUnits(s)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %w", p, w, err)
+			}
+			limits = append(limits, Limit{Asset: asset, Window: w, Max: max})
+		}
+		for w := range windows {
+			if _, ok := windowDurations[w]; !ok {
+				return nil, fmt.Errorf("%s/%s: unknown window (want %s)", p, w, strings.Join(Windows, ", "))
+			}
+		}
+	}
+	return limits, nil
+}
+
+// parseAddress accepts a 0x address. Mixed-case addresses must carry a
+// valid EIP-55 checksum.
+func parseAddress(s string) (common.Address, error) {
+	if !strings.HasPrefix(s, "0x") || !common.IsHexAddress(s) {
+		return common.Address{}, fmt.Errorf("invalid address %q", s)
+	}
+	addr := common.HexToAddress(s)
+	if digits := s[2:]; digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && addr.Hex() != s {
+		return common.Address{}, fmt.Errorf("address %q has a bad EIP-55 checksum", s)
+	}
+	return addr, nil
+}
+
+// parseAmount accepts a plain decimal wei string or any amount with a unit
+// that helpers.ParseWeiString accepts.
+func parseAmount(s string) (*big.Int, error) {
+	if s != "" && strings.Trim(s, "0123456789") == "" {
+		if len(s) > 1 && s[0] == '0' {
+			return nil, fmt.Errorf("invalid amount %q (no leading zeros)", s)
+		}
+		return parseWei(s)
+	}
+	wei, err := helpers.ParseWeiString(s)
+	if err != nil {
+		return nil, err
+	}
+	return parseWei(wei)
+}
+
+// maxDecimals is the most digits a ratio in a policy file may have after
+// the decimal point, so that it is written back exactly.
+const maxDecimals = 18
+
+// parseDecimal accepts a positive decimal number such as "3" or "2.5".
+func parseDecimal(s string) (*big.Rat, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || strings.Trim(whole, "0123456789") != "" || len(whole) > 1 && whole[0] == '0' ||
+		strings.Contains(s, ".") && (frac == "" || strings.Trim(frac, "0123456789") != "") {
+		return nil, fmt.Errorf("invalid number %q (want a decimal such as 2.5)", s)
+	}
+	if len(frac) > maxDecimals {
+		return nil, fmt.Errorf("invalid number %q (at most %d decimal places)", s, maxDecimals)
+	}
+	x, ok := new(big.Rat).SetString(s)
+	if !ok || x.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid number %q (must be above zero)", s)
+	}
+	return x, nil
+}
+
+// formatDecimal writes x, parsed by parseDecimal, without trailing zeros.
+func formatDecimal(x *big.Rat) string {
+	s := x.FloatString(maxDecimals)
+	return strings.TrimRight(strings.TrimRight(s, "0"), ".")
+}
+
+// parseBaseUnits accepts a plain decimal integer, for token amounts whose
+// decimals are not known offline.
+func parseBaseUnits(s string) (*big.Int, error) {
+	if s == "" || strings.Trim(s, "0123456789") != "" || len(s) > 1 && s[0] == '0' {
+		return nil, fmt.Errorf("invalid amount %q (want token base units as a decimal integer)", s)
+	}
+	return parseWei(s)
+}
+
+// Document returns p in file form, with every field set explicitly:
+// amounts in wei, kinds and recipients sorted.
+func (p *Policy) Document() Document {
+	doc := Document{
+		V:                      DocumentVersion,
+		Version:                p.Version,
+		Chains:                 make(map[string]ChainDocument, len(p.Chains)),
+		UnauthenticatedIntents: p.UnauthenticatedIntents,
+		Destinations:           p.Destinations,
+		Clock:                  p.Clock,
+		RequireExpiry:          p.RequireExpiry,
+	}
+	doc.Rules = ruleDocuments(p.Rules)
+	for id, c := range p.Chains {
+		doc.Chains[strconv.FormatUint(id, 10)] = chainDocument(c)
+	}
+	if len(p.Accounts) > 0 {
+		doc.Accounts = make(map[string]AccountDocument, len(p.Accounts))
+	}
+	for index, a := range p.Accounts {
+		doc.Accounts[strconv.FormatUint(uint64(index), 10)] = a.document()
+	}
+	return doc
+}
+
+func ruleDocuments(rs []*Rule) []RuleDocument {
+	var docs []RuleDocument
+	for _, r := range rs {
+		docs = append(docs, RuleDocument{Name: r.Name, Expr: r.Expr.String(), Action: r.Action})
+	}
+	return docs
+}
+
+func chainDocument(c *ChainPolicy) ChainDocument {
+	cd := ChainDocument{
+		Name:                    c.Name,
+		Profile:                 c.Profile,
+		MaxFeePerGasWei:         c.MaxFeePerGasWei.String(),
+		MaxPriorityFeePerGasWei: c.MaxPriorityFeePerGasWei.String(),
+		MaxValueWei:             c.MaxValueWei.String(),
+		MaxGasLimit:             c.MaxGasLimit,
+		Kinds:                   []string{},
+	}
+	for k, ok := range c.Kinds {
+		if ok {
+			cd.Kinds = append(cd.Kinds, k)
+		}
+	}
+	slices.Sort(cd.Kinds)
+	if c.Recipients != nil {
+		cd.Recipients = []string{}
+		for a, ok := range c.Recipients {
+			if ok {
+				cd.Recipients = append(cd.Recipients, a.Hex())
+			}
+		}
+		slices.Sort(cd.Recipients)
+	}
+	if c.MinFeePerGasWei != nil {
+		cd.MinFeePerGasWei = c.MinFeePerGasWei.String()
+	}
+	if c.MinPriorityFeePerGasWei != nil {
+		cd.MinPriorityFeePerGasWei = c.MinPriorityFeePerGasWei.String()
+	}
+	if c.MaxTotalFeeWei != nil {
+		cd.MaxTotalFeeWei = c.MaxTotalFeeWei.String()
+	}
+	if c.MaxFeeToValuePercent != nil {
+		cd.MaxFeeToValuePercent = formatDecimal(c.MaxFeeToValuePercent)
+	}
+	if c.MaxBaseFeeMultiple != nil {
+		cd.MaxBaseFeeMultiple = formatDecimal(c.MaxBaseFeeMultiple)
+	}
+	cd.RequireBaseFee = c.RequireBaseFee
+	if len(c.Limits) > 0 {
+		cd.Limits = make(map[string]map[string]string)
+		for _, l := range c.Limits {
+			if cd.Limits[l.Asset] == nil {
+				cd.Limits[l.Asset] = make(map[string]string)
+			}
+			cd.Limits[l.Asset][l.Window] = l.Max.String()
+		}
+	}
+	return cd
+}
+
+// documentJSON is the serialization hashed by Hash. Map keys (chain IDs)
+// are sorted by encoding/json.
+func (p *Policy) documentJSON() []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(p.Document()); err != nil {
+		// Document only holds JSON-safe types.
+		panic(err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
@@ -1,33 +1,117 @@
 package policy
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"math/big"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+
+	"coldsign/addrbook"
 	"coldsign/intent"
+	"coldsign/ledger"
+)
+
+// Intent authentication modes.
+const (
+	IntentAuthRefuse = "refuse" // refuse unsigned or unknown-key intents
+	IntentAuthWarn   = "warn"   // sign them after a loud warning
+)
+
+// Clock modes.
+const (
+	ClockTrusted   = "trusted"   // judge intent expiry by the local clock
+	ClockUntrusted = "untrusted" // show the validity window; the operator judges
 )
 
+// Destination modes, applied with the address book.
+const (
+	DestinationsDenylist  = "denylist"  // refuse denylisted entries; anything else may be sent to
+	DestinationsAllowlist = "allowlist" // only address book entries that are not denylisted
+)
+
+// clockSkew is how far in the future createdAt may be before the intent
+// (or the local clock) is considered wrong.
+const clockSkew = 5 * time.Minute
+
 type Policy struct {
-	AllowedChainIDs map[uint64]bool
+	// Version numbers the policy's revisions. A signed policy must have
+	// one, and an older version is never accepted after a newer one.
+	Version uint64
+
+	// Chains holds the limits for each allowed chain; intents for any
+	// other chain are refused.
+	Chains map[uint64]*ChainPolicy
+
+	// UnauthenticatedIntents decides what happens to intents that are not
+	// signed by a trusted coordinator: IntentAuthRefuse or IntentAuthWarn.
+	UnauthenticatedIntents string
 
-	MaxFeePerGasWei        *big.Int
-	MaxPriorityFeePerGasWei *big.Int
-	MaxValueWei            *big.Int
+	// Destinations decides which recipients the address book allows:
+	// DestinationsDenylist or DestinationsAllowlist.
+	Destinations string
+
+	// Clock says whether the local clock can be trusted to enforce
+	// validUntil: ClockTrusted or ClockUntrusted.
+	Clock string
+
+	// RequireExpiry refuses intents without validUntil.
+	RequireExpiry bool
+
+	// Rules are custom checks, evaluated in order (see EvaluateRules).
+	Rules []*Rule
+
+	// Accounts holds per-account policies by BIP-44 index (see
+	// ForAccount). Account is set only on the policy ForAccount returns.
+	Accounts map[uint32]*Account
+	Account  *Account
+
+	// Source names where the policy came from: a file path or
+	// DefaultSource. It is not part of the hash.
+	Source string
+
+	// Signers names the administrators whose signatures were verified
+	// (see Verify), and Threshold how many were required. Signers is nil
+	// for an unsigned policy. Neither is part of the hash.
+	Signers   []string
+	Threshold int
+
+	// Overrides lists the command-line overrides applied after the policy
+	// was loaded, e.g. "--clock untrusted", for the review. It is not part
+	// of the hash; the fields it changed are.
+	Overrides []string
 }
 
 func Default() *Policy {
+	mainnet, _ := newChainPolicy(ProfileMainnet)
+	mainnet.Name = "Ethereum"
+
 	return &Policy{
-		AllowedChainIDs: map[uint64]bool{
-			1: true, // mainnet only (Phase 0)
-		},
+		// Mainnet only; other chains need a policy file
+		Chains: map[uint64]*ChainPolicy{1: mainnet},
+
+		// Unsigned intents remain usable until coordinators are configured
+		UnauthenticatedIntents: IntentAuthWarn,
+
+		Destinations: DestinationsDenylist,
 
-		// Conservative, adjustable later
-		MaxFeePerGasWei:        big.NewInt(200_000_000_000), // 200 gwei
-		MaxPriorityFeePerGasWei: big.NewInt(10_000_000_000), // 10 gwei
-		MaxValueWei:            big.NewInt(0).Mul(big.NewInt(1000), big.NewInt(1e18)), // 1000 ETH
+		Clock: ClockTrusted,
+
+		Source: DefaultSource,
 	}
 }
 
+// Hash returns a SHA-256 over the policy in file form (see Document), so
+// the policy in force can be recorded and compared. Two files that differ
+// only in layout or amount units hash the same; command-line overrides
+// change the hash.
+func (p *Policy) Hash() string {
+	sum := sha256.Sum256(p.documentJSON())
+	return hex.EncodeToString(sum[:])
+}
+
 func parseWei(s string) (*big.Int, error) {
 	x, ok := new(big.Int).SetString(s, 10)
 	if !ok {
@@ -36,25 +120,98 @@ func parseWei(s string) (*big.Int, error) {
 	return x, nil
 }
 
-func (p *Policy) Enforce(in *intent.EthSendIntent) error {
-	if !p.AllowedChainIDs[in.ChainID] {
-		return fmt.Errorf("chainId %d not allowed by policy", in.ChainID)
+// Chain returns the limits for chainID, or nil if the chain is not allowed.
+func (p *Policy) Chain(chainID uint64) *ChainPolicy {
+	return p.Chains[chainID]
+}
+
+// Usage returns the rolling limits that apply to the intent's asset on
+// its chain, with what the sender has sent in each window according to
+// led. A transaction for a nonce already in the ledger adds only what it
+// sends beyond the largest amount already signed for that nonce. It fails
+// if limits apply but there is no ledger to count from.
+func (p *Policy) Usage(in *intent.EthSendIntent, led *ledger.Ledger, now time.Time) ([]LimitUsage, error) {
+	c := p.ForAccount(in.From.Index).Chain(in.ChainID)
+	if c == nil {
+		return nil, nil
+	}
+	asset, amountStr := ledger.Asset(in)
+	amount, err := parseWei(amountStr)
+	if err != nil {
+		return nil, err
+	}
+	from := common.HexToAddress(in.FromAddress)
+
+	var usage []LimitUsage
+	for _, l := range c.Limits {
+		if l.Asset != asset {
+			continue
+		}
+		if led == nil {
+			return nil, fmt.Errorf("chain %d has spending limits but no ledger is available", in.ChainID)
+		}
+		since := now.Add(-l.Duration())
+		usage = append(usage, LimitUsage{
+			Limit: l,
+			Spent: led.Spent(in.ChainID, from, asset, since),
+			After: led.SpentWith(in.ChainID, from, asset, since, in.Nonce, amount),
+		})
+	}
+	return usage, nil
+}
+
+// EnforceDestination applies the destination mode. dest is the address
+// book entry for the intent's recipient, or nil if it has none.
+func (p *Policy) EnforceDestination(to string, dest *addrbook.Entry) error {
+	if dest != nil && dest.Deny {
+		return fmt.Errorf("destination %s (%s) is denylisted", to, dest.Label)
+	}
+	if dest == nil && p.Destinations == DestinationsAllowlist {
+		return fmt.Errorf("destination %s is not in the address book; policy allows listed destinations only", to)
+	}
+	return nil
+}
+
+// EnforceIntentAuth applies the intent authentication policy. It returns a
+// non-empty warning when an unauthenticated intent is allowed through.
+// A signature that fails to verify is always refused.
+func (p *Policy) EnforceIntentAuth(res intent.AuthResult) (string, error) {
+	switch res.Status {
+	case intent.AuthTrusted:
+		return "", nil
+	case intent.AuthInvalid:
+		return "", fmt.Errorf("intent signature is invalid")
 	}
 
-	maxFee, _ := parseWei(in.MaxFeePerGasWei)
-	if maxFee.Cmp(p.MaxFeePerGasWei) > 0 {
-		return fmt.Errorf("maxFeePerGas exceeds policy limit")
+	if p.UnauthenticatedIntents == IntentAuthWarn {
+		return fmt.Sprintf("intent is %s; its origin cannot be verified", res), nil
 	}
+	return "", fmt.Errorf("intent is %s; policy requires a trusted coordinator signature", res)
+}
 
-	maxPrio, _ := parseWei(in.MaxPriorityFeePerGasWei)
-	if maxPrio.Cmp(p.MaxPriorityFeePerGasWei) > 0 {
-		return fmt.Errorf("maxPriorityFeePerGas exceeds policy limit")
+// EnforceExpiry checks the intent's validity window against now. With an
+// untrusted clock nothing is refused on time alone; instead a warning
+// states the window so the operator can judge it.
+func (p *Policy) EnforceExpiry(in *intent.EthSendIntent, now time.Time) (string, error) {
+	until := in.ExpiryTime()
+	if until.IsZero() && p.RequireExpiry {
+		return "", fmt.Errorf("intent has no validUntil; policy requires an expiry")
 	}
+	stamp := func(t time.Time) string { return t.UTC().Format(time.RFC3339) }
 
-	value, _ := parseWei(in.ValueWei)
-	if value.Cmp(p.MaxValueWei) > 0 {
-		return fmt.Errorf("value exceeds policy limit")
+	if p.Clock == ClockUntrusted {
+		if until.IsZero() {
+			return "", nil
+		}
+		return fmt.Sprintf("local clock is not trusted; confirm it is before %s (local clock reads %s)",
+			stamp(until), stamp(now)), nil
 	}
 
-	return nil
+	if !until.IsZero() && now.After(until) {
+		return "", fmt.Errorf("intent expired at %s (local clock %s)", stamp(until), stamp(now))
+	}
+	if created := in.CreatedTime(); created.After(now.Add(clockSkew)) {
+		return "", fmt.Errorf("intent createdAt %s is in the future (local clock %s)", stamp(created), stamp(now))
+	}
+	return "", nil
 }
@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"coldsign/addrbook"
+	"coldsign/intent"
+	"coldsign/ledger"
+	"coldsign/rules"
+)
+
+// Rule actions: what happens when a rule's expression is false.
+const (
+	RuleRefuse = "refuse" // refuse the intent
+	RuleWarn   = "warn"   // show a warning and continue
+)
+
+// Rule is a named custom check written in the rules expression language.
+// The expression states what must hold for the intent.
+type Rule struct {
+	Name   string
+	Expr   *rules.Expr
+	Action string // RuleRefuse or RuleWarn
+}
+
+// RuleVars are the variables available to rule expressions. Amounts are
+// integers in wei, or token base units.
+var RuleVars = rules.Vars{
+	"kind":          rules.String, // intent kind
+	"chainId":       rules.Int,
+	"chainName":     rules.String, // name given in the policy, or ""
+	"profile":       rules.String, // chain profile
+	"from":          rules.String, // sender address, EIP-55
+	"fromIndex":     rules.Int,    // BIP-44 index
+	"to":            rules.String, // recipient address, EIP-55
+	"label":         rules.String, // recipient's address book label, or ""
+	"category":      rules.String, // recipient's address book category, or ""
+	"listed":        rules.Bool,   // recipient is in the address book
+	"asset":         rules.String, // "ETH" or the token address
+	"amount":        rules.Int,    // value, or tokenAmount for tokens
+	"value":         rules.Int,    // ETH value (0 for token transfers)
+	"token":         rules.String, // token address, or ""
+	"nonce":         rules.Int,
+	"gas":           rules.Int,    // gas limit
+	"maxFee":        rules.Int,    // maxFeePerGas
+	"tip":           rules.Int,    // maxPriorityFeePerGas
+	"baseFee":       rules.Int,    // the intent's baseFeeWei, or 0
+	"totalFee":      rules.Int,    // worst-case fee: gas limit times maxFee
+	"authenticated": rules.Bool,   // signed by a trusted coordinator
+	"coordinator":   rules.String, // that coordinator's name, or ""
+	"intentId":      rules.String,
+	"role":          rules.String, // the sender's account role, or ""
+	"weekday":       rules.Int,    // 0 (Sunday) to 6, UTC by the local clock
+	"hour":          rules.Int,    // 0 to 23, UTC by the local clock
+	"spent24h":      rules.Int,    // sent by this sender in this asset and chain, before this transaction
+	"spent7d":       rules.Int,
+	"spent30d":      rules.Int,
+}
+
+// Facts is what the cold machine knows about an intent beyond its own
+// fields.
+type Facts struct {
+	Dest   *addrbook.Entry   // recipient's address book entry, or nil
+	Auth   intent.AuthResult // origin
+	Ledger *ledger.Ledger    // nil: spent* are unavailable
+	Now    time.Time
+}
+
+// RuleResult is the outcome of one rule.
+type RuleResult struct {
+	Rule   *Rule
+	Passed bool
+	Err    error // evaluation failed; treated as a refusal
+}
+
+// EvaluateRules runs every rule against the intent, in policy order; the
+// sender's account rules, if any, come last.
+func (p *Policy) EvaluateRules(in *intent.EthSendIntent, f Facts) []RuleResult {
+	p = p.ForAccount(in.From.Index)
+	if len(p.Rules) == 0 {
+		return nil
+	}
+	env := ruleEnv(in, p.Chain(in.ChainID), f)
+	if p.Account != nil {
+		env["role"] = p.Account.Role
+	}
+	results := make([]RuleResult, len(p.Rules))
+	for i, r := range p.Rules {
+		ok, err := r.Expr.Eval(env)
+		results[i] = RuleResult{Rule: r, Passed: ok, Err: err}
+	}
+	return results
+}
+
+func ruleEnv(in *intent.EthSendIntent, c *ChainPolicy, f Facts) rules.Env {
+	wei := func(s string) *big.Int {
+		x, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return new(big.Int)
+		}
+		return x
+	}
+	asset, amount := ledger.Asset(in)
+	from := common.HexToAddress(in.FromAddress)
+	now := f.Now.UTC()
+
+	env := rules.Env{
+		"kind":          in.Kind,
+		"chainId":       new(big.Int).SetUint64(in.ChainID),
+		"chainName":     "",
+		"profile":       "",
+		"from":          from.Hex(),
+		"fromIndex":     big.NewInt(int64(in.From.Index)),
+		"to":            common.HexToAddress(in.To).Hex(),
+		"label":         "",
+		"category":      "",
+		"listed":        f.Dest != nil,
+		"asset":         asset,
+		"amount":        wei(amount),
+		"value":         wei(in.ValueWei),
+		"token":         "",
+		"nonce":         new(big.Int).SetUint64(in.Nonce),
+		"gas":           new(big.Int).SetUint64(in.Gas()),
+		"maxFee":        wei(in.MaxFeePerGasWei),
+		"tip":           wei(in.MaxPriorityFeePerGasWei),
+		"baseFee":       new(big.Int),
+		"totalFee":      new(big.Int).Mul(new(big.Int).SetUint64(in.Gas()), wei(in.MaxFeePerGasWei)),
+		"authenticated": f.Auth.Authenticated(),
+		"coordinator":   f.Auth.Coordinator,
+		"intentId":      in.IntentID,
+		"role":          "",
+		"weekday":       big.NewInt(int64(now.Weekday())),
+		"hour":          big.NewInt(int64(now.Hour())),
+	}
+	if c != nil {
+		env["chainName"], env["profile"] = c.Name, c.Profile
+	}
+	if f.Dest != nil {
+		env["label"], env["category"] = f.Dest.Label, f.Dest.Category
+	}
+	if in.Kind == intent.KindERC20Transfer {
+		env["token"] = asset
+	}
+	if in.BaseFeeWei != "" && f.Auth.Authenticated() {
+		env["baseFee"] = wei(in.BaseFeeWei)
+	}
+	if f.Ledger != nil {
+		for _, w := range Windows {
+			env["spent"+w] = f.Ledger.Spent(in.ChainID, from, asset, f.Now.Add(-windowDurations[w]))
+		}
+	}
+	return env
+}
@@ -0,0 +1,214 @@
+package policy
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"coldsign/helpers"
+	"coldsign/intent"
+	"coldsign/keys"
+)
+
+// signingDomain is prepended to the policy hash before an administrator
+// signs it, so a policy signature cannot be mistaken for any other.
+const signingDomain = "coldsign-policy-v1\x00"
+
+// SignatureVersion is the signature file format version.
+const SignatureVersion = 1
+
+// Admin is a key trusted to approve policies.
+type Admin struct {
+	Name string
+	Key  keys.PublicKey
+}
+
+// Admins is the set of administrator keys and how many of them must sign
+// a policy.
+type Admins struct {
+	Threshold int
+	Keys      []Admin
+	Source    string // file path
+}
+
+// adminsFile is the on-disk form:
+//
+//	{"v":1,"threshold":2,"admins":[{"name":"alice","alg":"ed25519","publicKey":"<hex>"}, ...]}
+type adminsFile struct {
+	V         int `json:"v"`
+	Threshold int `json:"threshold"`
+	Admins    []struct {
+		Name      string `json:"name"`
+		Alg       string `json:"alg"`
+		PublicKey string `json:"publicKey"`
+	} `json:"admins"`
+}
+
+// LoadAdmins reads the administrator keys. The threshold must be between 1
+// and the number of keys; names and keys must be unique.
+func LoadAdmins(path string) (*Admins, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f adminsFile
+	if err := intent.DecodeStrict(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.V != 1 {
+		return nil, fmt.Errorf("%s: /v: unsupported admins file version %d (want 1)", path, f.V)
+	}
+
+	a := &Admins{Threshold: f.Threshold, Source: path}
+	for i, e := range f.Admins {
+		if !intent.ValidLabel(e.Name) {
+			return nil, fmt.Errorf("%s: /admins/%d/name: must be 1 to 64 letters, digits or . _ : -", path, i)
+		}
+		key, err := keys.ParsePublicKey(e.Alg, e.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: /admins/%d: %w", path, i, err)
+		}
+		for _, other := range a.Keys {
+			if other.Name == e.Name {
+				return nil, fmt.Errorf("%s: /admins/%d/name: %q is listed twice", path, i, e.Name)
+			}
+			if other.Key.Equal(key) {
+				return nil, fmt.Errorf("%s: /admins/%d/publicKey: same key as %q", path, i, other.Name)
+			}
+		}
+		a.Keys = append(a.Keys, Admin{Name: e.Name, Key: key})
+	}
+	if len(a.Keys) == 0 {
+		return nil, fmt.Errorf("%s: /admins: must list at least one key", path)
+	}
+	if a.Threshold < 1 || a.Threshold > len(a.Keys) {
+		return nil, fmt.Errorf("%s: /threshold: must be between 1 and %d", path, len(a.Keys))
+	}
+	return a, nil
+}
+
+// Signatures is a detached signature file, kept next to the policy file
+// (see SignaturePath):
+//
+//	{"v":1,"policyHash":"<hex>","signatures":[{"alg":"ed25519","publicKey":"<hex>","sig":"<hex>"}]}
+//
+// Each signature covers signingDomain followed by the policy hash in hex,
+// so it survives changes to the file's layout but not to its content.
+type Signatures struct {
+	V          int              `json:"v"`
+	PolicyHash string           `json:"policyHash"`
+	Signatures []SignatureEntry `json:"signatures"`
+}
+
+// SignatureEntry is one administrator's signature.
+type SignatureEntry struct {
+	Alg       string `json:"alg"`
+	PublicKey string `json:"publicKey"`
+	Sig       string `json:"sig"`
+}
+
+// SignaturePath returns the signature file for a policy file.
+func SignaturePath(policyPath string) string { return policyPath + ".sig" }
+
+// LoadSignatures reads a signature file.
+func LoadSignatures(path string) (*Signatures, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Signatures
+	if err := intent.DecodeStrict(b, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.V != SignatureVersion {
+		return nil, fmt.Errorf("%s: /v: unsupported signature file version %d (want %d)", path, s.V, SignatureVersion)
+	}
+	return &s, nil
+}
+
+// Save writes s to path, replacing any previous file.
+func (s *Signatures) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	os.Remove(tmp) // left over from an interrupted save
+	if err := helpers.WriteNewFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func signedMessage(policyHash string) []byte {
+	return []byte(signingDomain + policyHash)
+}
+
+// Sign adds key's signature over p to s, which must be for p's hash. A
+// key that has already signed is replaced.
+func (p *Policy) Sign(s *Signatures, key *keys.PrivateKey) error {
+	if p.Version == 0 {
+		return fmt.Errorf("/version: a signed policy needs a version number of 1 or more")
+	}
+	hash := p.Hash()
+	if s.PolicyHash != hash {
+		return fmt.Errorf("signature file is for policy %s, not %s", s.PolicyHash, hash)
+	}
+	sig, err := key.Sign(signedMessage(hash))
+	if err != nil {
+		return err
+	}
+	pub := key.Public()
+	entry := SignatureEntry{Alg: pub.Alg, PublicKey: pub.Hex(), Sig: hex.EncodeToString(sig)}
+	for i, e := range s.Signatures {
+		if e.Alg == entry.Alg && strings.EqualFold(e.PublicKey, entry.PublicKey) {
+			s.Signatures[i] = entry
+			return nil
+		}
+	}
+	s.Signatures = append(s.Signatures, entry)
+	return nil
+}
+
+// Verify checks s against the administrator keys and, if at least the
+// threshold of distinct administrators signed p, records them in
+// p.Signers. Signatures by keys that are not administrators are ignored;
+// a signature that does not verify is an error.
+func (p *Policy) Verify(s *Signatures, admins *Admins) error {
+	hash := p.Hash()
+	if s.PolicyHash != hash {
+		return fmt.Errorf("signatures are for policy %s, but the file hashes to %s; it has changed since it was signed",
+			intent.ShortHash(s.PolicyHash), intent.ShortHash(hash))
+	}
+	if p.Version == 0 {
+		return fmt.Errorf("/version: a signed policy needs a version number of 1 or more")
+	}
+
+	signed := make(map[string]bool)
+	var signers []string
+	for i, e := range s.Signatures {
+		key, err := keys.ParsePublicKey(e.Alg, e.PublicKey)
+		if err != nil {
+			return fmt.Errorf("/signatures/%d: %w", i, err)
+		}
+		sig, err := hex.DecodeString(e.Sig)
+		if err != nil || !key.Verify(signedMessage(hash), sig) {
+			return fmt.Errorf("/signatures/%d: INVALID signature (%s %s)", i, key.Alg, key.Fingerprint())
+		}
+		for _, a := range admins.Keys {
+			if a.Key.Equal(key) && !signed[a.Name] {
+				signed[a.Name] = true
+				signers = append(signers, a.Name)
+			}
+		}
+	}
+	if len(signers) < admins.Threshold {
+		return fmt.Errorf("signed by %d of the %d administrators required (%d configured)",
+			len(signers), admins.Threshold, len(admins.Keys))
+	}
+	p.Signers = signers
+	p.Threshold = admins.Threshold
+	return nil
+}
@@ -0,0 +1,263 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"coldsign/keys"
+)
+
+// testPolicy returns a policy as if loaded from a file, at version.
+func testPolicy(version uint64) *Policy {
+	p := Default()
+	p.Version = version
+	p.Source = "policy.json"
+	return p
+}
+
+func generateKeys(t *testing.T, algs ...string) []*keys.PrivateKey {
+	t.Helper()
+	var ks []*keys.PrivateKey
+	for _, alg := range algs {
+		k, err := keys.Generate(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ks = append(ks, k)
+	}
+	return ks
+}
+
+func testAdmins(threshold int, ks []*keys.PrivateKey) *Admins {
+	a := &Admins{Threshold: threshold}
+	for i, k := range ks {
+		a.Keys = append(a.Keys, Admin{Name: string(rune('a' + i)), Key: k.Public()})
+	}
+	return a
+}
+
+func TestVerify(t *testing.T) {
+	ks := generateKeys(t, keys.AlgEd25519, keys.AlgSecp256k1, keys.AlgEd25519)
+	outsider := generateKeys(t, keys.AlgEd25519)[0]
+	admins := testAdmins(2, ks)
+
+	tests := []struct {
+		name    string
+		signers []*keys.PrivateKey
+		edit    func(p *Policy, s *Signatures)
+		wantErr string
+		want    []string
+	}{
+		{name: "threshold met", signers: ks[:2], want: []string{"a", "b"}},
+		{name: "all admins", signers: ks, want: []string{"a", "b", "c"}},
+		{name: "below threshold", signers: ks[:1], wantErr: "signed by 1 of the 2 administrators required (3 configured)"},
+		{name: "no signatures", wantErr: "signed by 0 of the 2"},
+		{name: "outsider does not count", signers: []*keys.PrivateKey{ks[0], outsider}, wantErr: "signed by 1 of the 2"},
+		{
+			name:    "same admin twice counts once",
+			signers: ks[:1],
+			edit: func(p *Policy, s *Signatures) {
+				s.Signatures = append(s.Signatures, s.Signatures[0])
+			},
+			wantErr: "signed by 1 of the 2",
+		},
+		{
+			name:    "policy changed after signing",
+			signers: ks[:2],
+			edit:    func(p *Policy, s *Signatures) { p.RequireExpiry = true },
+			wantErr: "it has changed since it was signed",
+		},
+		{
+			name:    "signature over another policy",
+			signers: ks[:2],
+			edit: func(p *Policy, s *Signatures) {
+				other := testPolicy(p.Version + 1)
+				s.Signatures[1].Sig = signatureFor(t, other, ks[1]).Sig
+			},
+			wantErr: "/signatures/1: INVALID signature",
+		},
+		{
+			name:    "bad signature hex",
+			signers: ks[:2],
+			edit:    func(p *Policy, s *Signatures) { s.Signatures[0].Sig = "zz" },
+			wantErr: "/signatures/0: INVALID signature",
+		},
+		{
+			name:    "bad public key",
+			signers: ks[:2],
+			edit:    func(p *Policy, s *Signatures) { s.Signatures[0].PublicKey = "00" },
+			wantErr: "/signatures/0: ed25519 public key must be 32 bytes",
+		},
+		{
+			name:    "unversioned policy",
+			signers: ks[:2],
+			edit: func(p *Policy, s *Signatures) {
+				p.Version = 0
+				s.PolicyHash = p.Hash()
+			},
+			wantErr: "needs a version number",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testPolicy(3)
+			s := &Signatures{V: SignatureVersion, PolicyHash: p.Hash()}
+			for _, k := range tt.signers {
+				if err := p.Sign(s, k); err != nil {
+					t.Fatalf("Sign: %v", err)
+				}
+			}
+			if tt.edit != nil {
+				tt.edit(p, s)
+			}
+
+			err := p.Verify(s, admins)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Verify error = %v, want %q", err, tt.wantErr)
+				}
+				if p.Signers != nil {
+					t.Fatalf("Signers = %v after a failed Verify", p.Signers)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if strings.Join(p.Signers, ",") != strings.Join(tt.want, ",") || p.Threshold != 2 {
+				t.Fatalf("Signers = %v, Threshold = %d; want %v, 2", p.Signers, p.Threshold, tt.want)
+			}
+		})
+	}
+}
+
+// signatureFor returns k's signature entry over p.
+func signatureFor(t *testing.T, p *Policy, k *keys.PrivateKey) SignatureEntry {
+	t.Helper()
+	s := &Signatures{V: SignatureVersion, PolicyHash: p.Hash()}
+	if err := p.Sign(s, k); err != nil {
+		t.Fatal(err)
+	}
+	return s.Signatures[0]
+}
+
+func TestSign(t *testing.T) {
+	k := generateKeys(t, keys.AlgEd25519)[0]
+	p := testPolicy(1)
+	s := &Signatures{V: SignatureVersion, PolicyHash: p.Hash()}
+	for i := 0; i < 2; i++ {
+		if err := p.Sign(s, k); err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+	}
+	if len(s.Signatures) != 1 {
+		t.Errorf("signing twice with one key left %d signatures, want 1", len(s.Signatures))
+	}
+
+	if err := testPolicy(2).Sign(s, k); err == nil || !strings.Contains(err.Error(), "signature file is for policy") {
+		t.Errorf("Sign with another policy's signature file: error = %v", err)
+	}
+	if err := testPolicy(0).Sign(&Signatures{PolicyHash: testPolicy(0).Hash()}, k); err == nil {
+		t.Errorf("Sign of an unversioned policy succeeded")
+	}
+
+	path := filepath.Join(t.TempDir(), "policy.json.sig")
+	if err := os.WriteFile(path+".tmp", []byte("stale"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(path); err != nil {
+		t.Fatalf("Save over a stale temporary file: %v", err)
+	}
+	loaded, err := LoadSignatures(path)
+	if err != nil {
+		t.Fatalf("LoadSignatures: %v", err)
+	}
+	if err := p.Verify(loaded, testAdmins(1, []*keys.PrivateKey{k})); err != nil {
+		t.Fatalf("Verify after Save and LoadSignatures: %v", err)
+	}
+}
+
+// signed returns p as if verified.
+func signed(p *Policy) *Policy {
+	p.Signers = []string{"a"}
+	p.Threshold = 1
+	return p
+}
+
+func TestCheckDowngrade(t *testing.T) {
+	changed := signed(testPolicy(5))
+	changed.RequireExpiry = true
+
+	tests := []struct {
+		name    string
+		p       *Policy
+		wantErr string
+	}{
+		{"same policy", signed(testPolicy(5)), ""},
+		{"newer version", signed(testPolicy(6)), ""},
+		{"older version", signed(testPolicy(4)), "older than version 5"},
+		{"same version, different policy", changed, "a changed policy needs a new version"},
+		{"unsigned", testPolicy(6), "an unsigned policy is refused"},
+		{"built-in defaults", Default(), "no policy file is present"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state", "policy.json")
+			if err := signed(testPolicy(5)).RecordAccepted(path, time.Now()); err != nil {
+				t.Fatalf("RecordAccepted: %v", err)
+			}
+			err := tt.p.CheckDowngrade(path)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("CheckDowngrade: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("CheckDowngrade error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecordAccepted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "policy.json")
+
+	// Nothing accepted yet: anything goes, and unsigned policies are not
+	// recorded.
+	for _, p := range []*Policy{Default(), testPolicy(9)} {
+		if err := p.CheckDowngrade(path); err != nil {
+			t.Fatalf("CheckDowngrade with no state: %v", err)
+		}
+		if err := p.RecordAccepted(path, time.Now()); err != nil {
+			t.Fatalf("RecordAccepted: %v", err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("an unsigned policy was recorded (stat error %v)", err)
+	}
+
+	// Only a newer version replaces the record.
+	for _, v := range []uint64{3, 2, 3} {
+		if err := signed(testPolicy(v)).RecordAccepted(path, time.Now()); err != nil {
+			t.Fatalf("RecordAccepted(%d): %v", v, err)
+		}
+	}
+	st, err := loadAccepted(path)
+	if err != nil {
+		t.Fatalf("loadAccepted: %v", err)
+	}
+	if st.Version != 3 || st.Hash != testPolicy(3).Hash() {
+		t.Fatalf("recorded version %d hash %s, want version 3", st.Version, st.Hash)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("state file mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"v":1,"version":3,"version":1,"hash":"","time":""}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := signed(testPolicy(3)).CheckDowngrade(path); err == nil || !strings.Contains(err.Error(), "duplicate field") {
+		t.Fatalf("CheckDowngrade with a tampered state file: error = %v", err)
+	}
+}
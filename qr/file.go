@@ -0,0 +1,59 @@
+package qr
+
+import (
+	"fmt"
+	"strings"
+
+	"coldsign/helpers"
+)
+
+// fileQuietZone is the number of light modules around PNG/SVG codes
+// (the QR specification asks for 4).
+const fileQuietZone = 4
+
+// WritePNG encodes payload and writes it to path as a PNG image, readable
+// by the owner only. An existing file is never overwritten. Each module is
+// opts.Scale pixels wide.
+func WritePNG(path, payload string, opts Options) error {
+	code, err := encode(payload, opts)
+	if err != nil {
+		return err
+	}
+	// rsc.io/qr draws a 4-module quiet zone itself.
+	return helpers.WriteNewFile(path, code.PNG(), 0o600)
+}
+
+// SVG returns payload encoded as a standalone SVG document.
+func SVG(payload string, opts Options) (string, error) {
+	code, err := encode(payload, opts)
+	if err != nil {
+		return "", err
+	}
+
+	n := code.Size + 2*fileQuietZone
+	px := n * opts.Scale
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n", px, px, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", n, n)
+	b.WriteString(`<path fill="#000" d="`)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+fileQuietZone, y+fileQuietZone)
+			}
+		}
+	}
+	b.WriteString(`"/>` + "\n</svg>\n")
+	return b.String(), nil
+}
+
+// WriteSVG encodes payload and writes it to path as an SVG image, like
+// WritePNG.
+func WriteSVG(path, payload string, opts Options) error {
+	svg, err := SVG(payload, opts)
+	if err != nil {
+		return err
+	}
+	return helpers.WriteNewFile(path, []byte(svg), 0o600)
+}
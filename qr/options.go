@@ -0,0 +1,98 @@
+package qr
+
+import (
+	"fmt"
+	"strings"
+
+	rscqr "rsc.io/qr"
+)
+
+// Level is the QR error-correction level.
+type Level int
+
+const (
+	L Level = iota // ~7% recovery, smallest code
+	M              // ~15% recovery
+	Q              // ~25% recovery
+	H              // ~30% recovery, densest code
+)
+
+// Options controls how a payload is rendered.
+type Options struct {
+	Level Level // error-correction level
+	Scale int   // pixels per module for PNG/SVG output
+	ASCII bool  // terminal: plain ASCII instead of Unicode half blocks
+	Alnum bool  // upper-case hex payloads so they encode in alphanumeric mode
+}
+
+// DefaultOptions matches the historical terminal output (level L, half blocks).
+func DefaultOptions() Options {
+	return Options{Level: L, Scale: 8}
+}
+
+// ParseLevel parses an error-correction level name (L, M, Q or H).
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "L":
+		return L, nil
+	case "M":
+		return M, nil
+	case "Q":
+		return Q, nil
+	case "H":
+		return H, nil
+	default:
+		return L, fmt.Errorf("invalid QR level %q (want L, M, Q or H)", s)
+	}
+}
+
+func (o Options) validate() error {
+	if o.Level < L || o.Level > H {
+		return fmt.Errorf("invalid QR level")
+	}
+	if o.Scale < 1 || o.Scale > 64 {
+		return fmt.Errorf("QR module size must be between 1 and 64")
+	}
+	return nil
+}
+
+// Payload returns the string that is actually encoded for payload.
+//
+// With Alnum set, 0x-prefixed hex is upper-cased: QR alphanumeric mode
+// covers 0-9 and A-Z and stores 5.5 bits per character instead of 8, so
+// long raw transactions produce a noticeably smaller code. Hex decoders
+// accept either case, but EIP-55 checksum casing is lost, so this is
+// opt-in.
+func Payload(payload string, opts Options) string {
+	if opts.Alnum && isHex0x(payload) {
+		return strings.ToUpper(payload)
+	}
+	return payload
+}
+
+func isHex0x(s string) bool {
+	if len(s) < 3 || s[0] != '0' || (s[1] != 'x' && s[1] != 'X') {
+		return false
+	}
+	for _, c := range s[2:] {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'f', c >= 'A' && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// encode validates opts and encodes payload into a QR bitmap.
+func encode(payload string, opts Options) (*rscqr.Code, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	code, err := rscqr.Encode(Payload(payload, opts), rscqr.Level(opts.Level))
+	if err != nil {
+		return nil, fmt.Errorf("qr encode: %w", err)
+	}
+	code.Scale = opts.Scale
+	return code, nil
+}
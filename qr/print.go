@@ -1,11 +1,17 @@
 package qr
 
 import (
+	"io"
 	"os"
+	"strings"
 
 	"github.com/mdp/qrterminal/v3"
+	rscqr "rsc.io/qr"
 )
 
+// quietZone is the number of light modules around terminal codes.
+const quietZone = 2
+
 func PrintToTerminal(payload string) {
 	cfg := qrterminal.Config{
 		Level:      qrterminal.L,
@@ -14,3 +20,53 @@ func PrintToTerminal(payload string) {
 	}
 	qrterminal.GenerateWithConfig(payload, cfg)
 }
+
+// PrintWithOptions renders payload to w using opts.
+// ASCII output draws light modules as "##" and dark modules as spaces,
+// which scans correctly on dark-background terminals without Unicode.
+func PrintWithOptions(w io.Writer, payload string, opts Options) error {
+	code, err := encode(payload, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.ASCII {
+		writeASCII(w, code)
+		return nil
+	}
+
+	cfg := qrterminal.Config{
+		Level:      rscqr.Level(opts.Level),
+		Writer:     w,
+		HalfBlocks: true,
+	}
+	qrterminal.GenerateWithConfig(Payload(payload, opts), cfg)
+	return nil
+}
+
+func writeASCII(w io.Writer, code *rscqr.Code) {
+	const light, dark = "##", "  "
+	width := code.Size + 2*quietZone
+
+	border := strings.Repeat(light, width) + "\n"
+	var b strings.Builder
+	for i := 0; i < quietZone; i++ {
+		b.WriteString(border)
+	}
+	for y := 0; y < code.Size; y++ {
+		b.WriteString(strings.Repeat(light, quietZone))
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				b.WriteString(dark)
+			} else {
+				b.WriteString(light)
+			}
+		}
+		b.WriteString(strings.Repeat(light, quietZone))
+		b.WriteString("\n")
+	}
+	for i := 0; i < quietZone; i++ {
+		b.WriteString(border)
+	}
+	io.WriteString(w, b.String())
+}
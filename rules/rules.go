@@ -0,0 +1,412 @@
+// Package rules compiles and evaluates policy rule expressions: a small,
+// side-effect-free subset of Go expression syntax over typed variables.
+//
+// Values are booleans, integers (arbitrary precision, so wei amounts are
+// exact) and strings. Supported are literals (true, false, integers and
+// double-quoted strings), variables, parentheses, the operators
+//
+//	||  &&  !  ==  !=  <  <=  >  >=  +  -  *  /  %
+//
+// and the functions listed in Funcs. There are no loops, assignments,
+// selectors or indexing, so an expression always terminates. Expressions
+// are type-checked when compiled; evaluation can only fail on division by
+// zero or a variable missing from the environment.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"coldsign/helpers"
+)
+
+// Limits on expressions, to keep rules reviewable and evaluation cheap.
+const (
+	MaxLen   = 1024 // bytes of source
+	maxNodes = 256  // syntax tree nodes
+)
+
+// Type is the type of a value.
+type Type int
+
+const (
+	Bool Type = iota + 1
+	Int
+	String
+)
+
+func (t Type) String() string {
+	switch t {
+	case Bool:
+		return "bool"
+	case Int:
+		return "int"
+	case String:
+		return "string"
+	default:
+		return "invalid"
+	}
+}
+
+// Vars declares the variables an expression may use and their types.
+type Vars map[string]Type
+
+// Env holds variable values for Eval: bool, *big.Int or string, matching
+// the declared types.
+type Env map[string]any
+
+// Funcs describes the built-in functions.
+var Funcs = map[string]string{
+	"eth":   `eth("1.5") is 1.5 ETH in wei; the argument must be a string literal`,
+	"gwei":  `gwei("20") is 20 gwei in wei; the argument must be a string literal`,
+	"lower": `lower(s) is s in lower case`,
+	"oneOf": `oneOf(x, a, b, ...) is true if x equals any of the others`,
+}
+
+// Expr is a compiled expression.
+type Expr struct {
+	src  string
+	eval evalFunc
+}
+
+type evalFunc func(Env) (any, error)
+
+// String returns the source of the expression.
+func (e *Expr) String() string { return e.src }
+
+// Compile parses and type-checks src, which must be a bool expression
+// over vars.
+func Compile(src string, vars Vars) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, errors.New("empty expression")
+	}
+	if len(src) > MaxLen {
+		return nil, fmt.Errorf("expression longer than %d bytes", MaxLen)
+	}
+	node, err := parser.ParseExpr(src)
+	if err != nil {
+		return nil, fmt.Errorf("syntax error: %v", err)
+	}
+	n := 0
+	ast.Inspect(node, func(ast.Node) bool { n++; return true })
+	if n > maxNodes {
+		return nil, fmt.Errorf("expression too complex (more than %d nodes)", maxNodes)
+	}
+
+	c := &compiler{vars: vars}
+	f, t, err := c.compile(node)
+	if err != nil {
+		return nil, err
+	}
+	if t != Bool {
+		return nil, fmt.Errorf("expression is %s, want bool", t)
+	}
+	return &Expr{src: src, eval: f}, nil
+}
+
+// Eval evaluates the expression in env.
+func (e *Expr) Eval(env Env) (bool, error) {
+	v, err := e.eval(env)
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+type compiler struct {
+	vars Vars
+}
+
+func (c *compiler) compile(node ast.Expr) (evalFunc, Type, error) {
+	switch n := node.(type) {
+	case *ast.ParenExpr:
+		return c.compile(n.X)
+	case *ast.BasicLit:
+		return literal(n)
+	case *ast.Ident:
+		return c.ident(n)
+	case *ast.UnaryExpr:
+		return c.unary(n)
+	case *ast.BinaryExpr:
+		return c.binary(n)
+	case *ast.CallExpr:
+		return c.call(n)
+	default:
+		return nil, 0, fmt.Errorf("unsupported syntax %s", describe(node))
+	}
+}
+
+func constant(v any) evalFunc { return func(Env) (any, error) { return v, nil } }
+
+func literal(n *ast.BasicLit) (evalFunc, Type, error) {
+	switch n.Kind {
+	case token.INT:
+		x, ok := new(big.Int).SetString(n.Value, 0)
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid integer %s", n.Value)
+		}
+		return constant(x), Int, nil
+	case token.STRING:
+		s, err := strconv.Unquote(n.Value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid string %s", n.Value)
+		}
+		return constant(s), String, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported literal %s (integers and strings only)", n.Value)
+	}
+}
+
+func (c *compiler) ident(n *ast.Ident) (evalFunc, Type, error) {
+	switch n.Name {
+	case "true":
+		return constant(true), Bool, nil
+	case "false":
+		return constant(false), Bool, nil
+	}
+	t, ok := c.vars[n.Name]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown variable %q", n.Name)
+	}
+	name := n.Name
+	return func(env Env) (any, error) {
+		v, ok := env[name]
+		if !ok {
+			return nil, fmt.Errorf("%s is not available", name)
+		}
+		return v, nil
+	}, t, nil
+}
+
+func (c *compiler) unary(n *ast.UnaryExpr) (evalFunc, Type, error) {
+	x, t, err := c.compile(n.X)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case n.Op == token.NOT && t == Bool:
+		return func(env Env) (any, error) {
+			v, err := x(env)
+			if err != nil {
+				return nil, err
+			}
+			return !v.(bool), nil
+		}, Bool, nil
+	case n.Op == token.SUB && t == Int:
+		return func(env Env) (any, error) {
+			v, err := x(env)
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Int).Neg(v.(*big.Int)), nil
+		}, Int, nil
+	default:
+		return nil, 0, fmt.Errorf("operator %s does not apply to %s", n.Op, t)
+	}
+}
+
+func (c *compiler) binary(n *ast.BinaryExpr) (evalFunc, Type, error) {
+	x, xt, err := c.compile(n.X)
+	if err != nil {
+		return nil, 0, err
+	}
+	y, yt, err := c.compile(n.Y)
+	if err != nil {
+		return nil, 0, err
+	}
+	if xt != yt {
+		return nil, 0, fmt.Errorf("mismatched types %s %s %s", xt, n.Op, yt)
+	}
+
+	switch n.Op {
+	case token.LAND, token.LOR:
+		if xt != Bool {
+			return nil, 0, fmt.Errorf("operator %s does not apply to %s", n.Op, xt)
+		}
+		and := n.Op == token.LAND
+		return func(env Env) (any, error) {
+			a, err := x(env)
+			if err != nil {
+				return nil, err
+			}
+			if a.(bool) != and { // short-circuit
+				return a, nil
+			}
+			return y(env)
+		}, Bool, nil
+
+	case token.EQL, token.NEQ:
+		neq := n.Op == token.NEQ
+		return both(x, y, func(a, b any) (any, error) {
+			return equal(a, b) != neq, nil
+		}), Bool, nil
+
+	case token.LSS, token.LEQ, token.GTR, token.GEQ:
+		if xt != Int {
+			return nil, 0, fmt.Errorf("operator %s does not apply to %s", n.Op, xt)
+		}
+		op := n.Op
+		return both(x, y, func(a, b any) (any, error) {
+			c := a.(*big.Int).Cmp(b.(*big.Int))
+			switch op {
+			case token.LSS:
+				return c < 0, nil
+			case token.LEQ:
+				return c <= 0, nil
+			case token.GTR:
+				return c > 0, nil
+			default:
+				return c >= 0, nil
+			}
+		}), Bool, nil
+
+	case token.ADD, token.SUB, token.MUL, token.QUO, token.REM:
+		if xt != Int {
+			return nil, 0, fmt.Errorf("operator %s does not apply to %s", n.Op, xt)
+		}
+		op := n.Op
+		return both(x, y, func(a, b any) (any, error) {
+			p, q := a.(*big.Int), b.(*big.Int)
+			switch op {
+			case token.ADD:
+				return new(big.Int).Add(p, q), nil
+			case token.SUB:
+				return new(big.Int).Sub(p, q), nil
+			case token.MUL:
+				return new(big.Int).Mul(p, q), nil
+			}
+			if q.Sign() == 0 {
+				return nil, errors.New("division by zero")
+			}
+			if op == token.QUO {
+				return new(big.Int).Quo(p, q), nil
+			}
+			return new(big.Int).Rem(p, q), nil
+		}), Int, nil
+
+	default:
+		return nil, 0, fmt.Errorf("unsupported operator %s", n.Op)
+	}
+}
+
+func both(x, y evalFunc, f func(a, b any) (any, error)) evalFunc {
+	return func(env Env) (any, error) {
+		a, err := x(env)
+		if err != nil {
+			return nil, err
+		}
+		b, err := y(env)
+		if err != nil {
+			return nil, err
+		}
+		return f(a, b)
+	}
+}
+
+func equal(a, b any) bool {
+	if x, ok := a.(*big.Int); ok {
+		return x.Cmp(b.(*big.Int)) == 0
+	}
+	return a == b
+}
+
+func (c *compiler) call(n *ast.CallExpr) (evalFunc, Type, error) {
+	fn, ok := n.Fun.(*ast.Ident)
+	if !ok || n.Ellipsis.IsValid() {
+		return nil, 0, fmt.Errorf("only built-in functions can be called (got %s)", describe(n.Fun))
+	}
+
+	switch fn.Name {
+	case "eth", "gwei":
+		if len(n.Args) != 1 {
+			return nil, 0, fmt.Errorf("%s takes one argument", fn.Name)
+		}
+		lit, ok := n.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return nil, 0, fmt.Errorf("%s needs a string literal, e.g. %s(\"1.5\")", fn.Name, fn.Name)
+		}
+		s, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid string %s", lit.Value)
+		}
+		unit := " ETH"
+		if fn.Name == "gwei" {
+			unit = " gwei"
+		}
+		wei, err := helpers.ParseWeiString(s + unit)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s(%s): %v", fn.Name, lit.Value, err)
+		}
+		x, _ := new(big.Int).SetString(wei, 10)
+		return constant(x), Int, nil
+
+	case "lower":
+		if len(n.Args) != 1 {
+			return nil, 0, errors.New("lower takes one argument")
+		}
+		x, t, err := c.compile(n.Args[0])
+		if err != nil {
+			return nil, 0, err
+		}
+		if t != String {
+			return nil, 0, fmt.Errorf("lower needs a string, got %s", t)
+		}
+		return func(env Env) (any, error) {
+			v, err := x(env)
+			if err != nil {
+				return nil, err
+			}
+			return strings.ToLower(v.(string)), nil
+		}, String, nil
+
+	case "oneOf":
+		if len(n.Args) < 2 {
+			return nil, 0, errors.New("oneOf takes a value and at least one candidate")
+		}
+		var args []evalFunc
+		var want Type
+		for i, a := range n.Args {
+			f, t, err := c.compile(a)
+			if err != nil {
+				return nil, 0, err
+			}
+			if i == 0 {
+				want = t
+			} else if t != want {
+				return nil, 0, fmt.Errorf("oneOf: argument %d is %s, want %s", i+1, t, want)
+			}
+			args = append(args, f)
+		}
+		return func(env Env) (any, error) {
+			x, err := args[0](env)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range args[1:] {
+				v, err := f(env)
+				if err != nil {
+					return nil, err
+				}
+				if equal(x, v) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}, Bool, nil
+
+	default:
+		return nil, 0, fmt.Errorf("unknown function %q", fn.Name)
+	}
+}
+
+// describe names a syntax node for error messages.
+func describe(n ast.Node) string {
+	name := fmt.Sprintf("%T", n)
+	return strings.TrimSuffix(strings.TrimPrefix(name, "*ast."), "Expr")
+}
@@ -0,0 +1,188 @@
+package rules
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+var testVars = Vars{
+	"b":      Bool,
+	"value":  Int,
+	"zero":   Int,
+	"listed": Bool,
+	"label":  String,
+}
+
+func testEnv() Env {
+	return Env{
+		"b":      true,
+		"value":  big.NewInt(1_500_000_000_000_000_000),
+		"zero":   new(big.Int),
+		"listed": true,
+		"label":  "Treasury",
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"empty", "  ", "empty expression"},
+		{"syntax", "value >", "syntax error"},
+		{"not bool", "value + 1", "expression is int, want bool"},
+		{"unknown variable", "spent > 0", `unknown variable "spent"`},
+		{"mismatched types", "value == label", "mismatched types int == string"},
+		{"and on ints", "value && zero", "operator && does not apply to int"},
+		{"less on strings", `label < "b"`, "operator < does not apply to string"},
+		{"arithmetic on bools", "listed + listed == listed", "operator + does not apply to bool"},
+		{"not on int", "!value", "operator ! does not apply to int"},
+		{"negate string", `-label == ""`, "operator - does not apply to string"},
+		{"float literal", "value > 1.5", "unsupported literal 1.5"},
+		{"selector", "value.x > 0", "unsupported syntax Selector"},
+		{"index", "label[0] == 1", "unsupported syntax Index"},
+		{"unknown function", "max(value, 1) > 0", `unknown function "max"`},
+		{"method call", "label.lower() == \"\"", "only built-in functions can be called"},
+		{"variadic call", `oneOf(label, "a"...)`, "only built-in functions can be called"},
+		{"eth no args", "value > eth()", "eth takes one argument"},
+		{"eth variable", "value > eth(label)", "eth needs a string literal"},
+		{"eth int literal", "value > eth(1)", "eth needs a string literal"},
+		{"eth below one wei", `value > eth("0.0000000000000000001")`, "more precise than 1 wei"},
+		{"eth negative", `value > eth("-1")`, "invalid amount"},
+		{"eth exponent", `value > eth("1e18")`, `eth("1e18")`},
+		{"eth leading zero", `value > eth("01")`, "invalid amount"},
+		{"eth unit inside", `value > eth("1 gwei")`, `eth("1 gwei")`},
+		{"gwei below one wei", `value > gwei("0.0000000001")`, "more precise than 1 wei"},
+		{"lower on int", "lower(value) == \"\"", "lower needs a string, got int"},
+		{"oneOf one argument", "oneOf(label)", "oneOf takes a value and at least one candidate"},
+		{"oneOf mixed types", `oneOf(label, "a", 1)`, "oneOf: argument 3 is int, want string"},
+		{"too long", "listed || " + strings.Repeat(" ", MaxLen), "longer than 1024 bytes"},
+		{"too complex", strings.Repeat("b&&", 200) + "b", "too complex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.src, testVars)
+			if err == nil {
+				t.Fatalf("Compile(%q) succeeded, want error containing %q", tt.src, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Compile(%q) error = %q, want it to contain %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{`value == eth("1.5")`, true},
+		{`value == gwei("1500000000")`, true},
+		{`value > eth("1") && value < eth("2")`, true},
+		{`value - eth("1") == eth("0.5")`, true},
+		{`value * 2 == eth("3")`, true},
+		{`value / 3 == eth("0.5")`, true},
+		{`value % eth("1") == eth("0.5")`, true},
+		{`-value < zero`, true},
+		{`0x10 == 16`, true},
+		{`!listed`, false},
+		{`listed != false`, true},
+		{`lower(label) == "treasury"`, true},
+		{`oneOf(lower(label), "ops", "treasury")`, true},
+		{`oneOf(value, 1, 2)`, false},
+		{`(listed || zero > 0) && label != ""`, true},
+		// Wei amounts beyond 64 bits stay exact.
+		{`eth("100000000000") * eth("100000000000") > eth("1")`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := Compile(tt.src, testVars)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			got, err := e.Eval(testEnv())
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Eval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+		env             Env
+	}{
+		{"division by zero", "value / zero > 0", "division by zero", testEnv()},
+		{"remainder by zero", "value % zero == 0", "division by zero", testEnv()},
+		{"missing variable", "value > 0", "value is not available", Env{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Compile(tt.src, testVars)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			_, err = e.Eval(tt.env)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Eval error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// The right side of && and || is not evaluated once the left side decides
+// the result, so a guard can protect a division.
+func TestShortCircuit(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"zero != 0 && value / zero > 0", false},
+		{"zero == 0 || value / zero > 0", true},
+		{"listed || missing", true},
+		{"!listed && missing", false},
+	}
+	vars := Vars{"missing": Bool}
+	for k, v := range testVars {
+		vars[k] = v
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := Compile(tt.src, vars)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			got, err := e.Eval(testEnv())
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Eval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	src := "listed" + strings.Repeat(" ", MaxLen-len("listed"))
+	if _, err := Compile(src, testVars); err != nil {
+		t.Errorf("Compile at MaxLen: %v", err)
+	}
+	if _, err := Compile(src+" ", testVars); err == nil {
+		t.Errorf("Compile over MaxLen succeeded")
+	}
+
+	src = strings.Repeat("b&&", 30) + "b"
+	if _, err := Compile(src, testVars); err != nil {
+		t.Errorf("Compile of 31 operands: %v", err)
+	}
+	src = strings.Repeat("b&&", maxNodes) + "b"
+	if _, err := Compile(src, testVars); err == nil {
+		t.Errorf("Compile of %d operands succeeded", maxNodes+1)
+	}
+}
@@ -0,0 +1,81 @@
+package sealed
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"coldsign/helpers"
+	"coldsign/intent"
+)
+
+// deviceKeyDomain separates seed-derived device keys from any other use of
+// the same HD child key.
+const deviceKeyDomain = "coldsign-device-x25519-v1"
+
+// GenerateKey creates a random X25519 device key.
+func GenerateKey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// KeyFromSeedMaterial turns secret HD key material (see hd.DeriveDeviceSecret)
+// into an X25519 device key.
+func KeyFromSeedMaterial(secret []byte) (*ecdh.PrivateKey, error) {
+	h := sha256.New()
+	h.Write([]byte(deviceKeyDomain))
+	h.Write(secret)
+	return ecdh.X25519().NewPrivateKey(h.Sum(nil))
+}
+
+type keyFile struct {
+	Alg        string `json:"alg"`
+	PrivateKey string `json:"privateKey"`
+	PublicKey  string `json:"publicKey"`
+}
+
+// SaveKey writes priv to path with owner-only permissions. It refuses to
+// overwrite an existing file.
+func SaveKey(path string, priv *ecdh.PrivateKey) error {
+	b, err := json.MarshalIndent(keyFile{
+		Alg:        "x25519",
+		PrivateKey: hex.EncodeToString(priv.Bytes()),
+		PublicKey:  hex.EncodeToString(priv.PublicKey().Bytes()),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return helpers.WriteNewFile(path, append(b, '\n'), 0o600)
+}
+
+// LoadKey reads a key written by SaveKey, as strictly as an intent. The
+// stored publicKey must match the private key.
+func LoadKey(path string) (*ecdh.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var kf keyFile
+	if err := intent.DecodeStrict(b, &kf); err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	if kf.Alg != "x25519" {
+		return nil, fmt.Errorf("key file %s: not an x25519 key", path)
+	}
+	raw, err := hex.DecodeString(kf.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: invalid privateKey hex", path)
+	}
+	priv, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	if pub, err := hex.DecodeString(kf.PublicKey); err != nil || !bytes.Equal(pub, priv.PublicKey().Bytes()) {
+		return nil, fmt.Errorf("key file %s: /publicKey: does not match the private key", path)
+	}
+	return priv, nil
+}
@@ -0,0 +1,109 @@
+// Package sealed encrypts small payloads (intents, signed transactions) to an
+// X25519 public key for transport on removable media.
+//
+// A sealed payload is "coldenc:v1:<base64url(ephemeralPub || ciphertext)>".
+// The AES-256-GCM key is derived with HKDF-SHA256 from the X25519 shared
+// secret, salted with both public keys. Each message uses a fresh ephemeral
+// key, so a fixed nonce is safe. Sealing provides confidentiality only: it
+// does not say who produced the payload.
+package sealed
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const Prefix = "coldenc:v1:"
+
+const hkdfInfo = "coldsign-sealed-v1"
+
+// IsSealed reports whether s looks like a sealed payload.
+func IsSealed(s string) bool {
+	return strings.HasPrefix(strings.Trim(strings.TrimSpace(s), "\""), Prefix)
+}
+
+// ParseRecipient decodes a hex X25519 public key.
+func ParseRecipient(hexKey string) (*ecdh.PublicKey, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(hexKey), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient hex: %w", err)
+	}
+	pub, err := ecdh.X25519().NewPublicKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+	return pub, nil
+}
+
+// Seal encrypts plaintext to recipient.
+func Seal(recipient *ecdh.PublicKey, plaintext []byte) (string, error) {
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(eph, recipient, eph.PublicKey().Bytes(), recipient.Bytes())
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	out := aead.Seal(eph.PublicKey().Bytes(), nonce, plaintext, []byte(Prefix))
+	return Prefix + base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+// Open decrypts a sealed payload with the recipient's private key.
+func Open(priv *ecdh.PrivateKey, s string) ([]byte, error) {
+	s = strings.Trim(strings.TrimSpace(s), "\"")
+	if !strings.HasPrefix(s, Prefix) {
+		return nil, fmt.Errorf("not a sealed payload")
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, Prefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid sealed base64url: %w", err)
+	}
+	if len(raw) < 32 {
+		return nil, fmt.Errorf("sealed payload too short")
+	}
+
+	ephPub, err := ecdh.X25519().NewPublicKey(raw[:32])
+	if err != nil {
+		return nil, fmt.Errorf("invalid sealed payload: %w", err)
+	}
+	aead, err := newAEAD(priv, ephPub, raw[:32], priv.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	plain, err := aead.Open(nil, nonce, raw[32:], []byte(Prefix))
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed (wrong key or corrupted payload)")
+	}
+	return plain, nil
+}
+
+func newAEAD(priv *ecdh.PrivateKey, peer *ecdh.PublicKey, ephPub, recipientPub []byte) (cipher.AEAD, error) {
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	salt := append(append([]byte{}, ephPub...), recipientPub...)
+	key, err := hkdf.Key(sha256.New, shared, salt, hkdfInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -31,3 +31,10 @@ func SignEIP1559Tx(tx *types.Transaction, chainID uint64, priv *ecdsa.PrivateKey
 		TxHash:   signedTx.Hash().Hex(),
 	}, nil
 }
+
+// SigningHash returns the hash a signature over tx commits to. It depends
+// only on the unsigned transaction, so it identifies the transaction
+// before it is signed.
+func SigningHash(tx *types.Transaction, chainID uint64) string {
+	return types.LatestSignerForChainID(new(big.Int).SetUint64(chainID)).Hash(tx).Hex()
+}
@@ -21,6 +21,54 @@ func parseWei(s string) (*big.Int, error) {
 	return x, nil
 }
 
+// BuildUnsignedTx builds the type-2 (EIP-1559) transaction for any
+// supported intent kind.
+func BuildUnsignedTx(in *intent.EthSendIntent) (*types.Transaction, error) {
+	switch in.Kind {
+	case intent.KindEthSend:
+		return BuildUnsignedEthSendTx(in)
+	case intent.KindERC20Transfer:
+		return BuildUnsignedERC20TransferTx(in)
+	default:
+		return nil, fmt.Errorf("unsupported intent kind: %s", in.Kind)
+	}
+}
+
+// BuildUnsignedERC20TransferTx builds a type-2 (EIP-1559) call to
+// transfer(to, amount) on the intent's token contract.
+// Invariants:
+//   - value = 0
+//   - gas = intent gasLimit
+func BuildUnsignedERC20TransferTx(in *intent.EthSendIntent) (*types.Transaction, error) {
+	token := common.HexToAddress(in.Token)
+
+	data, err := in.TransferCalldata()
+	if err != nil {
+		return nil, err
+	}
+	maxFeeWei, err := parseWei(in.MaxFeePerGasWei)
+	if err != nil {
+		return nil, err
+	}
+	maxPrioWei, err := parseWei(in.MaxPriorityFeePerGasWei)
+	if err != nil {
+		return nil, err
+	}
+
+	txData := &types.DynamicFeeTx{
+		ChainID:   new(big.Int).SetUint64(in.ChainID),
+		Nonce:     in.Nonce,
+		To:        &token,
+		Value:     new(big.Int),
+		Gas:       in.GasLimit,
+		GasFeeCap: maxFeeWei,
+		GasTipCap: maxPrioWei,
+		Data:      data,
+	}
+
+	return types.NewTx(txData), nil
+}
+
 // BuildUnsignedEthSendTx builds a type-2 (EIP-1559) ETH transfer tx.
 // Phase 0 invariants:
 //   - gas = 21000